@@ -4,7 +4,6 @@ import (
 	"context"
 	"os"
 	"runtime"
-	"time"
 
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
@@ -13,18 +12,34 @@ import (
 
 	"github.com/BabichevDima/subManager/internal/config"
 	"github.com/BabichevDima/subManager/internal/db"
+	"github.com/BabichevDima/subManager/internal/events"
+	"github.com/BabichevDima/subManager/internal/graphqlapi"
+	"github.com/BabichevDima/subManager/internal/grpcserver"
+	"github.com/BabichevDima/subManager/internal/health"
 	"github.com/BabichevDima/subManager/internal/http/handlers"
 	"github.com/BabichevDima/subManager/internal/http/middleware"
+	"github.com/BabichevDima/subManager/internal/metrics"
+	"github.com/BabichevDima/subManager/internal/outbox"
+	"github.com/BabichevDima/subManager/internal/ratelimit"
+	"github.com/BabichevDima/subManager/internal/retention"
+	"github.com/BabichevDima/subManager/internal/tracing"
 
 	router "github.com/BabichevDima/subManager/internal/http"
 	"github.com/BabichevDima/subManager/internal/repository"
 	"github.com/BabichevDima/subManager/internal/usecase"
 	"github.com/BabichevDima/subManager/pkg/graceful"
 	"github.com/BabichevDima/subManager/pkg/logger"
+)
 
-	_ "github.com/BabichevDima/subManager/internal/docs"
+// служебные маршруты для балансировщика и Prometheus
+const (
+	livenessRoute  = "GET /healthz"
+	readinessRoute = "GET /readyz"
+	metricsRoute   = "GET /metrics"
 )
 
+var operationalRoutes = []string{livenessRoute, readinessRoute, metricsRoute}
+
 func main() {
 	_ = godotenv.Load(".env")
 
@@ -37,11 +52,17 @@ func main() {
 	if err != nil {
 		logger.Fatal("Config init error:", zap.Error(err))
 	}
+	if err := logger.Configure(config.Cfg.Logging.Level, config.Cfg.Logging.Format); err != nil {
+		logger.Fatal("Failed to configure logger", zap.Error(err))
+	}
 
-	logger.Info("Config loaded",
-		zap.String("db_host", config.Cfg.DB.Host),
-		zap.Int("db_port", config.Cfg.DB.Port),
-	)
+	// пароли, ключи API и секреты в лог не попадают
+	logger.Info("Config loaded", zap.Any("config", config.Cfg.Redacted()))
+
+	shutdownTracing, err := tracing.Init(context.Background(), config.Cfg.Tracing)
+	if err != nil {
+		logger.Fatal("Failed to init tracing", zap.Error(err))
+	}
 
 	dbConn, err := db.InitPostgres(config.Cfg.DB)
 	if err != nil {
@@ -49,24 +70,149 @@ func main() {
 	}
 	logger.Info("Successfully connected to the database")
 
+	if err := dbConn.Use(metrics.GormPlugin{}); err != nil {
+		logger.Fatal("Failed to init db metrics", zap.Error(err))
+	}
+	if err := dbConn.Use(tracing.GormPlugin{}); err != nil {
+		logger.Fatal("Failed to init db tracing", zap.Error(err))
+	}
+	sqlDB, err := dbConn.DB()
+	if err != nil {
+		logger.Fatal("Failed to get db pool", zap.Error(err))
+	}
+	metrics.RegisterDBStats(sqlDB)
+
 	subscriptionRepo := repository.NewSubscriptionRepository(dbConn)
-	subscriptionUsecase := usecase.NewSubscriptionUsecase(subscriptionRepo)
-	subscriptionHandler := handlers.NewSubscriptionHandler(subscriptionUsecase)
+	eventBus := events.NewBus(config.Cfg.Events.BufferSize)
+	subscriptionUsecase := usecase.NewSubscriptionUsecase(subscriptionRepo, eventBus)
+	subscriptionHandler := handlers.NewSubscriptionHandler(subscriptionUsecase, config.Cfg.Concurrency, config.Cfg.Server.WriteTimeout)
+
+	auditRepo := repository.NewAuditRepository(dbConn)
+	auditUsecase := usecase.NewAuditUsecase(auditRepo)
+	auditHandler := handlers.NewAuditHandler(auditUsecase)
+
+	calendarHandler := handlers.NewCalendarHandler(subscriptionUsecase, config.Cfg.Calendar)
+	eventsHandler := handlers.NewEventsHandler(eventBus, config.Cfg.Events)
+
+	routes := router.Routes(subscriptionHandler, auditHandler, calendarHandler, eventsHandler)
+	apiDoc := router.Document(routes)
+
+	graphqlHandler, err := graphqlapi.NewHandler(subscriptionUsecase, config.Cfg.Concurrency)
+	if err != nil {
+		logger.Fatal("Failed to build GraphQL schema", zap.Error(err))
+	}
 
 	mux := http.NewServeMux()
-	router.RegisterRoutes(mux, subscriptionHandler)
-	handler := middleware.RequestLogger(logger.L, mux)
+	router.RegisterRoutes(mux, routes, apiDoc, config.Cfg.Features.Swagger)
+	if config.Cfg.Features.GraphQL {
+		mux.Handle("POST /graphql", graphqlHandler)
+	}
+	mux.Handle(metricsRoute, metrics.Handler())
+
+	checker := health.NewChecker(config.Cfg.Health.Timeout, graceful.ShuttingDown)
+	checker.Add("database", db.Ping(dbConn))
+	checker.Add("migrations", db.CheckSchema(dbConn))
+	mux.HandleFunc(livenessRoute, checker.Live)
+	mux.HandleFunc(readinessRoute, checker.Ready)
+
+	idempotencyRepo := repository.NewIdempotencyRepository(dbConn)
+
+	var limiter *ratelimit.Limiter
+	closeRateLimitStore := func(context.Context) error { return nil }
+	if config.Cfg.RateLimit.Enabled {
+		var store ratelimit.Store
+		store, closeRateLimitStore, err = ratelimit.NewStore(config.Cfg.RateLimit)
+		if err != nil {
+			logger.Fatal("Failed to init rate limit store", zap.Error(err))
+		}
+		limiter, err = ratelimit.NewLimiter(config.Cfg.RateLimit, store)
+		if err != nil {
+			logger.Fatal("Failed to init rate limiter", zap.Error(err))
+		}
+	}
+
+	// внешний Recover ловит панику в middleware метрик, трассировки и логов, внутренний —
+	// в обработчиках, чтобы их ответ 500 попал в метрики, спан и лог запроса
+	handler := middleware.Chain(mux,
+		middleware.RequestID,
+		middleware.Recover,
+		middleware.Metrics(mux),
+		middleware.Tracing(mux),
+		middleware.RequestLogger(logger.L),
+		middleware.Recover,
+		middleware.SecurityHeaders(mux, config.Cfg.HTTP.Security),
+		middleware.CORS(config.Cfg.HTTP.CORS),
+		middleware.MaxBodySize(config.Cfg.HTTP.MaxBodySize),
+		middleware.Authenticate(config.Cfg.Auth.APIKeys),
+		middleware.RateLimit(mux, limiter, operationalRoutes...),
+		middleware.ValidateOpenAPI(apiDoc, config.Cfg.OpenAPI),
+		middleware.Idempotency(idempotencyRepo, config.Cfg.Idempotency.TTL, config.Cfg.HTTP.MaxBodySize),
+	)
 
 	server := &http.Server{
-		Addr:         ":8080",
+		Addr:         config.Cfg.Server.Addr,
 		Handler:      handler,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		ReadTimeout:  config.Cfg.Server.ReadTimeout,
+		WriteTimeout: config.Cfg.Server.WriteTimeout,
+		IdleTimeout:  config.Cfg.Server.IdleTimeout,
 	}
+	// потоки событий иначе держали бы server.Shutdown до таймаута
+	server.RegisterOnShutdown(eventBus.Close)
 
-	_, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// клиент NATS в сборку не входит: publisher nats включается передачей подключения
+	publisher, err := outbox.NewPublisher(config.Cfg.Outbox, nil)
+	if err != nil {
+		logger.Fatal("Failed to init outbox publisher", zap.Error(err))
+	}
+	outboxRepo := repository.NewOutboxRepository(dbConn)
+	outboxRelay := outbox.NewRelay(outboxRepo, publisher, config.Cfg.Outbox)
+	outboxRelay.Start(ctx)
+
+	var outboxCleaner *retention.OutboxCleaner
+	if config.Cfg.Outbox.SentRetention > 0 {
+		outboxCleaner = retention.NewOutboxCleaner(
+			outboxRepo,
+			config.Cfg.Outbox.SentRetention,
+			config.Cfg.Outbox.CleanupInterval,
+			config.Cfg.Outbox.CleanupBatchSize,
+		)
+		outboxCleaner.Start(ctx)
+	}
+
+	var purger *retention.Purger
+	if config.Cfg.Retention.DeletedSubscriptions > 0 {
+		purger = retention.NewPurger(
+			subscriptionRepo,
+			config.Cfg.Retention.DeletedSubscriptions,
+			config.Cfg.Retention.PurgeInterval,
+			config.Cfg.Retention.BatchSize,
+		)
+		purger.Start(ctx)
+	}
+
+	keyCleaner := retention.NewKeyCleaner(
+		idempotencyRepo,
+		config.Cfg.Idempotency.CleanupInterval,
+		config.Cfg.Idempotency.BatchSize,
+	)
+	keyCleaner.Start(ctx)
+
+	businessMetrics := metrics.NewBusinessRefresher(subscriptionRepo, config.Cfg.Metrics.RefreshInterval)
+	businessMetrics.Start(ctx)
+
+	checker.Add("outbox_relay", health.Worker(outboxRelay))
+	if outboxCleaner != nil {
+		checker.Add("outbox_cleaner", health.Worker(outboxCleaner))
+	}
+	if purger != nil {
+		checker.Add("retention_purger", health.Worker(purger))
+	}
+	checker.Add("idempotency_cleaner", health.Worker(keyCleaner))
+	checker.Add("business_metrics", health.Worker(businessMetrics))
+
 	logger.Info("Application starting",
 		zap.String("version", "1.0.0"),
 		zap.String("go_version", runtime.Version()),
@@ -76,17 +222,42 @@ func main() {
 		logger.Info("HTTP server is listening",
 			zap.String("address", "http://localhost"+server.Addr),
 			zap.String("docs", "http://localhost"+server.Addr+"/swagger"),
+			zap.String("openapi", "http://localhost"+server.Addr+"/openapi.json"),
+			zap.String("graphql", "http://localhost"+server.Addr+"/graphql"),
+			zap.String("metrics", "http://localhost"+server.Addr+"/metrics"),
 		)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Fatal("Failed to start server", zap.Error(err))
 		}
 	}()
 
+	var grpcServer *grpcserver.Server
+	if config.Cfg.GRPC.Addr != "" {
+		grpcServer = grpcserver.NewServer(
+			subscriptionUsecase,
+			config.Cfg.GRPC,
+			config.Cfg.Auth.APIKeys,
+			config.Cfg.Concurrency,
+		)
+		if err := grpcServer.Start(); err != nil {
+			logger.Fatal("Failed to start gRPC server", zap.Error(err))
+		}
+	}
+
 	graceful.GracefulShutdown(
 		cancel,
 		server,
 		logger.L,
-		5*time.Second,
+		config.Cfg.Server.ShutdownTimeout,
+		config.Cfg.Health.DrainDelay,
+		grpcServer.Shutdown,
+		outboxRelay.Shutdown,
+		outboxCleaner.Shutdown,
+		purger.Shutdown,
+		keyCleaner.Shutdown,
+		businessMetrics.Shutdown,
+		closeRateLimitStore,
 		db.ShutdownDB(dbConn),
+		shutdownTracing,
 	)
 }
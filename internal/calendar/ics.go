@@ -0,0 +1,174 @@
+package calendar
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+)
+
+const (
+	ContentType = "text/calendar; charset=utf-8"
+
+	prodID        = "-//subManager//Renewals//EN"
+	dateLayout    = "20060102"
+	dateTimeUTC   = "20060102T150405Z"
+	maxLineOctets = 75
+)
+
+// Token возвращает токен доступа к календарю пользователя
+func Token(secret, userID string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(userID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ValidToken сравнивает токен за постоянное время
+func ValidToken(secret, userID, token string) bool {
+	return hmac.Equal([]byte(Token(secret, userID)), []byte(token))
+}
+
+// Feed — календарь продлений одного пользователя
+type Feed struct {
+	Name          string
+	Subscriptions []dto.ResponseSubscription
+	Reminder      time.Duration
+	Now           time.Time
+}
+
+// Write пишет календарь в формате RFC 5545: по одному ежемесячно повторяющемуся
+// событию на подписку, начиная с месяца start_date и до месяца end_date включительно.
+func (f Feed) Write(w io.Writer) error {
+	out := &icsWriter{w: bufio.NewWriter(w)}
+
+	out.line("BEGIN:VCALENDAR")
+	out.line("VERSION:2.0")
+	out.line("PRODID:" + prodID)
+	out.line("CALSCALE:GREGORIAN")
+	out.line("METHOD:PUBLISH")
+	out.line("X-WR-CALNAME:" + escapeText(f.Name))
+
+	for _, sub := range f.Subscriptions {
+		if err := f.writeEvent(out, sub); err != nil {
+			return err
+		}
+	}
+
+	out.line("END:VCALENDAR")
+	return out.flush()
+}
+
+func (f Feed) writeEvent(out *icsWriter, sub dto.ResponseSubscription) error {
+	start, err := time.Parse("01-2006", sub.StartDate)
+	if err != nil {
+		return fmt.Errorf("subscription %s: %w", sub.ID, err)
+	}
+
+	out.line("BEGIN:VEVENT")
+	out.line("UID:" + sub.ID.String() + "@submanager")
+	out.line("DTSTAMP:" + f.Now.UTC().Format(dateTimeUTC))
+	if !sub.UpdatedAt.IsZero() {
+		out.line("LAST-MODIFIED:" + sub.UpdatedAt.UTC().Format(dateTimeUTC))
+	}
+	out.line("DTSTART;VALUE=DATE:" + start.Format(dateLayout))
+	out.line("DTEND;VALUE=DATE:" + start.AddDate(0, 0, 1).Format(dateLayout))
+
+	rrule := "RRULE:FREQ=MONTHLY"
+	if sub.EndDate != nil {
+		end, err := time.Parse("01-2006", *sub.EndDate)
+		if err != nil {
+			return fmt.Errorf("subscription %s: %w", sub.ID, err)
+		}
+		rrule += ";UNTIL=" + end.Format(dateLayout)
+	}
+	out.line(rrule)
+
+	summary := "Renewal: " + sub.ServiceName
+	out.line("SUMMARY:" + escapeText(summary))
+	out.line("DESCRIPTION:" + escapeText(fmt.Sprintf("%s renews monthly.\nPrice: %d", sub.ServiceName, sub.Price)))
+	out.line("TRANSP:TRANSPARENT")
+
+	if f.Reminder > 0 {
+		out.line("BEGIN:VALARM")
+		out.line("ACTION:DISPLAY")
+		out.line("DESCRIPTION:" + escapeText(summary))
+		out.line("TRIGGER:-" + duration(f.Reminder))
+		out.line("END:VALARM")
+	}
+
+	out.line("END:VEVENT")
+	return nil
+}
+
+// duration форматирует длительность по RFC 5545 (3.3.6)
+func duration(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("P%dD", d/(24*time.Hour))
+	}
+
+	var b strings.Builder
+	b.WriteString("PT")
+	if h := d / time.Hour; h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+		d -= h * time.Hour
+	}
+	if m := d / time.Minute; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if b.Len() == 2 {
+		b.WriteString("0M")
+	}
+	return b.String()
+}
+
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// icsWriter пишет строки с CRLF и переносом длинных строк по 75 октетов
+type icsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (o *icsWriter) line(s string) {
+	if o.err != nil {
+		return
+	}
+
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(s[cut]) {
+			cut--
+		}
+		o.write(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// строка продолжения начинается с пробела, он тоже входит в лимит
+		limit = maxLineOctets - 1
+	}
+	o.write(s + "\r\n")
+}
+
+func (o *icsWriter) write(s string) {
+	if o.err == nil {
+		_, o.err = o.w.WriteString(s)
+	}
+}
+
+func (o *icsWriter) flush() error {
+	if o.err != nil {
+		return o.err
+	}
+	return o.w.Flush()
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
@@ -0,0 +1,193 @@
+package calendar
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+)
+
+func TestFeedWrite(t *testing.T) {
+	endDate := "12-2025"
+	feed := Feed{
+		Name: "Renewals; user",
+		Subscriptions: []dto.ResponseSubscription{
+			{
+				ID:          uuid.MustParse("2f8c5a3e-1b7d-4c6a-9e0f-3a5b7c9d1e2f"),
+				ServiceName: "Yandex Plus, family",
+				Price:       400,
+				StartDate:   "07-2025",
+				EndDate:     &endDate,
+				UpdatedAt:   time.Date(2025, 7, 3, 10, 30, 0, 0, time.FixedZone("MSK", 3*60*60)),
+			},
+			{
+				ID:          uuid.MustParse("8d1e4f6a-2c3b-4a5d-8e7f-9a0b1c2d3e4f"),
+				ServiceName: "Netflix",
+				Price:       999,
+				StartDate:   "01-2024",
+			},
+		},
+		Reminder: 72 * time.Hour,
+		Now:      time.Date(2025, 8, 1, 9, 0, 0, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	if err := feed.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//subManager//Renewals//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		`X-WR-CALNAME:Renewals\; user`,
+		"BEGIN:VEVENT",
+		"UID:2f8c5a3e-1b7d-4c6a-9e0f-3a5b7c9d1e2f@submanager",
+		"DTSTAMP:20250801T090000Z",
+		"LAST-MODIFIED:20250703T073000Z",
+		"DTSTART;VALUE=DATE:20250701",
+		"DTEND;VALUE=DATE:20250702",
+		"RRULE:FREQ=MONTHLY;UNTIL=20251201",
+		`SUMMARY:Renewal: Yandex Plus\, family`,
+		`DESCRIPTION:Yandex Plus\, family renews monthly.\nPrice: 400`,
+		"TRANSP:TRANSPARENT",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		`DESCRIPTION:Renewal: Yandex Plus\, family`,
+		"TRIGGER:-P3D",
+		"END:VALARM",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:8d1e4f6a-2c3b-4a5d-8e7f-9a0b1c2d3e4f@submanager",
+		"DTSTAMP:20250801T090000Z",
+		"DTSTART;VALUE=DATE:20240101",
+		"DTEND;VALUE=DATE:20240102",
+		"RRULE:FREQ=MONTHLY",
+		"SUMMARY:Renewal: Netflix",
+		`DESCRIPTION:Netflix renews monthly.\nPrice: 999`,
+		"TRANSP:TRANSPARENT",
+		"BEGIN:VALARM",
+		"ACTION:DISPLAY",
+		"DESCRIPTION:Renewal: Netflix",
+		"TRIGGER:-P3D",
+		"END:VALARM",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+	if got := buf.String(); got != want {
+		t.Errorf("feed:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFeedWriteRejectsBadDates(t *testing.T) {
+	bad := "2025-12"
+	tests := []dto.ResponseSubscription{
+		{ServiceName: "bad start", StartDate: "2025-07"},
+		{ServiceName: "bad end", StartDate: "07-2025", EndDate: &bad},
+	}
+	for _, sub := range tests {
+		feed := Feed{Subscriptions: []dto.ResponseSubscription{sub}}
+		if err := feed.Write(&bytes.Buffer{}); err == nil {
+			t.Errorf("%s: no error", sub.ServiceName)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{24 * time.Hour, "P1D"},
+		{72 * time.Hour, "P3D"},
+		{time.Hour, "PT1H"},
+		{90 * time.Minute, "PT1H30M"},
+		{26 * time.Hour, "PT26H"},
+		{15 * time.Minute, "PT15M"},
+		{30 * time.Second, "PT0M"},
+	}
+	for _, tc := range tests {
+		if got := duration(tc.d); got != tc.want {
+			t.Errorf("duration(%v) = %q, want %q", tc.d, got, tc.want)
+		}
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain", "plain"},
+		{`a\b`, `a\\b`},
+		{"a;b,c", `a\;b\,c`},
+		{"line\r\nnext\nlast", `line\nnext\nlast`},
+	}
+	for _, tc := range tests {
+		if got := escapeText(tc.in); got != tc.want {
+			t.Errorf("escapeText(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestLineFolding(t *testing.T) {
+	tests := []struct {
+		name, line string
+	}{
+		{"short", "SUMMARY:short"},
+		{"exactly 75 octets", "SUMMARY:" + strings.Repeat("a", 67)},
+		{"ascii", "SUMMARY:" + strings.Repeat("a", 200)},
+		{"multibyte", "SUMMARY:" + strings.Repeat("подписка ", 30)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			out := &icsWriter{w: bufio.NewWriter(&buf)}
+			out.line(tc.line)
+			if err := out.flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			folded := strings.TrimSuffix(buf.String(), "\r\n")
+			for _, line := range strings.Split(folded, "\r\n") {
+				if len(line) > maxLineOctets {
+					t.Errorf("line of %d octets: %q", len(line), line)
+				}
+				if !utf8.ValidString(line) {
+					t.Errorf("line splits a rune: %q", line)
+				}
+			}
+			if got := strings.ReplaceAll(folded, "\r\n ", ""); got != tc.line {
+				t.Errorf("unfolded %q, want %q", got, tc.line)
+			}
+		})
+	}
+}
+
+func TestToken(t *testing.T) {
+	token := Token("secret", "user")
+	tests := []struct {
+		name, secret, userID, token string
+		want                        bool
+	}{
+		{"valid", "secret", "user", token, true},
+		{"other user", "secret", "other", token, false},
+		{"other secret", "rotated", "user", token, false},
+		{"truncated", "secret", "user", token[:len(token)-1], false},
+		{"empty", "secret", "user", "", false},
+	}
+	for _, tc := range tests {
+		if got := ValidToken(tc.secret, tc.userID, tc.token); got != tc.want {
+			t.Errorf("%s: ValidToken = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
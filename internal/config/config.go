@@ -1,36 +1,382 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
+// DBConfig подключение к Postgres: URL (postgres://...) или DSN целиком, либо отдельные параметры.
+// Пароль можно прочитать из файла password_file, например из секрета Docker или Kubernetes
 type DBConfig struct {
-	Host     string `mapstructure:"host"`
-	Port     int    `mapstructure:"port"`
-	User     string `mapstructure:"user"`
+	URL          string     `mapstructure:"url"`
+	Host         string     `mapstructure:"host"`
+	Port         int        `mapstructure:"port"`
+	User         string     `mapstructure:"user"`
+	Password     string     `mapstructure:"password"`
+	PasswordFile string     `mapstructure:"password_file"`
+	Name         string     `mapstructure:"name"`
+	TLS          DBTLS      `mapstructure:"tls"`
+	Pool         PoolConfig `mapstructure:"pool"`
+}
+
+// DBTLS шифрование соединения с базой. SSLMode — режим libpq: disable, allow, prefer,
+// require, verify-ca, verify-full, пустой — disable; RootCert проверяет сервер, Cert и Key —
+// клиентский сертификат
+type DBTLS struct {
+	SSLMode  string `mapstructure:"sslmode"`
+	RootCert string `mapstructure:"root_cert"`
+	Cert     string `mapstructure:"cert"`
+	Key      string `mapstructure:"key"`
+}
+
+// PoolConfig пул соединений с базой
+type PoolConfig struct {
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+}
+
+// ServerConfig HTTP-сервер
+type ServerConfig struct {
+	Addr            string        `mapstructure:"addr"`
+	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+}
+
+// LoggingConfig уровень и формат логов: json для сборщиков логов, console для разработки
+type LoggingConfig struct {
+	Level  string `mapstructure:"level"`
+	Format string `mapstructure:"format"`
+}
+
+// FeaturesConfig необязательные части API
+type FeaturesConfig struct {
+	GraphQL bool `mapstructure:"graphql"`
+	Swagger bool `mapstructure:"swagger"`
+}
+
+type OutboxConfig struct {
+	Publisher    string        `mapstructure:"publisher"`
+	HTTPURL      string        `mapstructure:"http_url"`
+	HTTPTimeout  time.Duration `mapstructure:"http_timeout"`
+	Subject      string        `mapstructure:"subject"`
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	// PublishTimeout ограничивает одну публикацию вне зависимости от транспорта
+	PublishTimeout time.Duration `mapstructure:"publish_timeout"`
+	// MaxAttempts после стольких неудач сообщение переводится в failed и не отправляется
+	MaxAttempts int `mapstructure:"max_attempts"`
+	// RetryBackoff отсрочка после первой неудачи; удваивается с каждой попыткой до MaxRetryBackoff
+	RetryBackoff    time.Duration `mapstructure:"retry_backoff"`
+	MaxRetryBackoff time.Duration `mapstructure:"max_retry_backoff"`
+	// SentRetention сколько хранить отправленные сообщения; 0 — не удалять
+	SentRetention    time.Duration `mapstructure:"sent_retention"`
+	CleanupInterval  time.Duration `mapstructure:"cleanup_interval"`
+	CleanupBatchSize int           `mapstructure:"cleanup_batch_size"`
+}
+
+type APIKey struct {
+	Key   string `mapstructure:"key"`
+	Actor string `mapstructure:"actor"`
+	Admin bool   `mapstructure:"admin"`
+}
+
+type AuthConfig struct {
+	APIKeys []APIKey `mapstructure:"api_keys"`
+}
+
+type RetentionConfig struct {
+	DeletedSubscriptions time.Duration `mapstructure:"deleted_subscriptions"`
+	PurgeInterval        time.Duration `mapstructure:"purge_interval"`
+	BatchSize            int           `mapstructure:"batch_size"`
+}
+
+type CalendarConfig struct {
+	Secret   string        `mapstructure:"secret"`
+	Reminder time.Duration `mapstructure:"reminder"`
+}
+
+type ConcurrencyConfig struct {
+	RequireIfMatch bool `mapstructure:"require_if_match"`
+}
+
+type IdempotencyConfig struct {
+	TTL             time.Duration `mapstructure:"ttl"`
+	CleanupInterval time.Duration `mapstructure:"cleanup_interval"`
+	BatchSize       int           `mapstructure:"batch_size"`
+}
+
+// OpenAPIConfig проверка запросов и ответов по документу OpenAPI
+type OpenAPIConfig struct {
+	ValidateRequests  bool `mapstructure:"validate_requests"`
+	ValidateResponses bool `mapstructure:"validate_responses"`
+}
+
+// EventsConfig шина событий для потока /api/subscriptions/events
+type EventsConfig struct {
+	BufferSize int           `mapstructure:"buffer_size"`
+	KeepAlive  time.Duration `mapstructure:"keep_alive"`
+}
+
+// MetricsConfig метрики Prometheus на /metrics
+type MetricsConfig struct {
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+}
+
+// GRPCConfig адрес gRPC API; пустой адрес отключает сервер
+type GRPCConfig struct {
+	Addr string `mapstructure:"addr"`
+}
+
+// TracingConfig трассировка OpenTelemetry. Экспортёр none | otlp | stdout;
+// stdout с заданным file пишет спаны в файл для проверки без коллектора
+type TracingConfig struct {
+	Exporter    string  `mapstructure:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	File        string  `mapstructure:"file"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+	ServiceName string  `mapstructure:"service_name"`
+}
+
+// HealthConfig проверки /readyz
+type HealthConfig struct {
+	Timeout    time.Duration `mapstructure:"timeout"`
+	DrainDelay time.Duration `mapstructure:"drain_delay"`
+}
+
+// CORSConfig доступ к API со страниц других источников; пустой allowed_origins отключает CORS
+type CORSConfig struct {
+	AllowedOrigins   []string      `mapstructure:"allowed_origins"`
+	AllowedMethods   []string      `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string      `mapstructure:"allowed_headers"`
+	ExposedHeaders   []string      `mapstructure:"exposed_headers"`
+	AllowCredentials bool          `mapstructure:"allow_credentials"`
+	MaxAge           time.Duration `mapstructure:"max_age"`
+}
+
+// SecurityConfig заголовки безопасности; CSP применяется к веб-интерфейсу из app/
+type SecurityConfig struct {
+	ContentSecurityPolicy string        `mapstructure:"content_security_policy"`
+	HSTSMaxAge            time.Duration `mapstructure:"hsts_max_age"`
+}
+
+// HTTPConfig общие middleware HTTP API
+type HTTPConfig struct {
+	MaxBodySize int64          `mapstructure:"max_body_size"`
+	CORS        CORSConfig     `mapstructure:"cors"`
+	Security    SecurityConfig `mapstructure:"security"`
+}
+
+// RateLimitRule лимит отдельного маршрута; route — шаблон как в ServeMux, requests 0 снимает лимит
+type RateLimitRule struct {
+	Route    string        `mapstructure:"route"`
+	Requests int           `mapstructure:"requests"`
+	Period   time.Duration `mapstructure:"period"`
+	Burst    int           `mapstructure:"burst"`
+}
+
+// RedisConfig подключение к Redis
+type RedisConfig struct {
+	Addr     string `mapstructure:"addr"`
 	Password string `mapstructure:"password"`
-	Name     string `mapstructure:"name"`
+	DB       int    `mapstructure:"db"`
+	Prefix   string `mapstructure:"prefix"`
+}
+
+// JWTConfig проверка bearer-токенов: HMACSecret для HS256/384/512 или PublicKeyFile —
+// PEM открытого ключа RSA или ECDSA для RS*/PS*/ES*. Issuer и Audience проверяются, если заданы
+type JWTConfig struct {
+	HMACSecret    string `mapstructure:"hmac_secret"`
+	PublicKeyFile string `mapstructure:"public_key_file"`
+	Issuer        string `mapstructure:"issuer"`
+	Audience      string `mapstructure:"audience"`
+}
+
+// RateLimitConfig ограничение частоты запросов на клиента: requests за period с запасом burst.
+// Store memory держит корзины в памяти экземпляра, redis — общими для всех экземпляров
+type RateLimitConfig struct {
+	Enabled        bool            `mapstructure:"enabled"`
+	Store          string          `mapstructure:"store"`
+	Redis          RedisConfig     `mapstructure:"redis"`
+	Requests       int             `mapstructure:"requests"`
+	Period         time.Duration   `mapstructure:"period"`
+	Burst          int             `mapstructure:"burst"`
+	Routes         []RateLimitRule `mapstructure:"routes"`
+	TrustedProxies []string        `mapstructure:"trusted_proxies"`
+	// JWT без ключа не используется; с ключом клиент с действительным bearer-токеном
+	// определяется по его subject
+	JWT JWTConfig `mapstructure:"jwt"`
 }
 
 type Config struct {
-	DB DBConfig `mapstructure:"db"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Logging     LoggingConfig     `mapstructure:"logging"`
+	Features    FeaturesConfig    `mapstructure:"features"`
+	DB          DBConfig          `mapstructure:"db"`
+	Outbox      OutboxConfig      `mapstructure:"outbox"`
+	Auth        AuthConfig        `mapstructure:"auth"`
+	Retention   RetentionConfig   `mapstructure:"retention"`
+	Calendar    CalendarConfig    `mapstructure:"calendar"`
+	Concurrency ConcurrencyConfig `mapstructure:"concurrency"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+	OpenAPI     OpenAPIConfig     `mapstructure:"openapi"`
+	GRPC        GRPCConfig        `mapstructure:"grpc"`
+	Events      EventsConfig      `mapstructure:"events"`
+	Metrics     MetricsConfig     `mapstructure:"metrics"`
+	Tracing     TracingConfig     `mapstructure:"tracing"`
+	Health      HealthConfig      `mapstructure:"health"`
+	HTTP        HTTPConfig        `mapstructure:"http"`
+	RateLimit   RateLimitConfig   `mapstructure:"rate_limit"`
 }
 
 var Cfg *Config
 
+// EnvPrefix префикс переменных окружения, переопределяющих ключи файла:
+// SUBMANAGER_SERVER_ADDR задаёт server.addr, SUBMANAGER_DB_POOL_MAX_OPEN_CONNS — db.pool.max_open_conns
+const EnvPrefix = "SUBMANAGER"
+
+// Init читает конфигурацию из файла path (пустой путь — только значения по умолчанию
+// и окружение), накладывает переменные SUBMANAGER_*, раскрывает ссылки ${env:...} и
+// ${file:...} и проверяет результат. Неизвестные ключи и недопустимые значения
+// перечисляются в ошибке все сразу.
 func Init(path string) error {
-	viper.SetConfigFile(path)
 	viper.SetConfigType("yaml")
+	viper.SetEnvPrefix(EnvPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
+	// DB_URL — привычное имя переменной у хостингов Postgres
+	if err := viper.BindEnv("db.url", EnvPrefix+"_DB_URL", "DB_URL"); err != nil {
+		return err
+	}
+
+	viper.SetDefault("server.addr", ":8080")
+	viper.SetDefault("server.read_timeout", 10*time.Second)
+	viper.SetDefault("server.write_timeout", 10*time.Second)
+	viper.SetDefault("server.idle_timeout", time.Minute)
+	viper.SetDefault("server.shutdown_timeout", 5*time.Second)
+
+	viper.SetDefault("logging.level", "info")
+	viper.SetDefault("logging.format", "json")
+
+	viper.SetDefault("features.graphql", true)
+	viper.SetDefault("features.swagger", true)
+
+	viper.SetDefault("db.url", "")
+	viper.SetDefault("db.host", "localhost")
+	viper.SetDefault("db.port", 5432)
+	viper.SetDefault("db.user", "postgres")
+	viper.SetDefault("db.password", "")
+	viper.SetDefault("db.password_file", "")
+	viper.SetDefault("db.name", "subscriptions_db")
+	viper.SetDefault("db.tls.sslmode", "")
+	viper.SetDefault("db.tls.root_cert", "")
+	viper.SetDefault("db.tls.cert", "")
+	viper.SetDefault("db.tls.key", "")
+	viper.SetDefault("db.pool.max_open_conns", 10)
+	viper.SetDefault("db.pool.max_idle_conns", 5)
+	viper.SetDefault("db.pool.conn_max_lifetime", time.Hour)
+	viper.SetDefault("db.pool.conn_max_idle_time", 30*time.Minute)
+
+	viper.SetDefault("outbox.publisher", "log")
+	viper.SetDefault("outbox.http_url", "")
+	viper.SetDefault("outbox.http_timeout", 5*time.Second)
+	viper.SetDefault("outbox.subject", "submanager")
+	viper.SetDefault("outbox.poll_interval", time.Second)
+	viper.SetDefault("outbox.batch_size", 100)
+	viper.SetDefault("outbox.publish_timeout", 5*time.Second)
+	viper.SetDefault("outbox.max_attempts", 10)
+	viper.SetDefault("outbox.retry_backoff", time.Second)
+	viper.SetDefault("outbox.max_retry_backoff", 10*time.Minute)
+	viper.SetDefault("outbox.sent_retention", 7*24*time.Hour)
+	viper.SetDefault("outbox.cleanup_interval", time.Hour)
+	viper.SetDefault("outbox.cleanup_batch_size", 1000)
+
+	viper.SetDefault("retention.deleted_subscriptions", 365*24*time.Hour)
+	viper.SetDefault("retention.purge_interval", time.Hour)
+	viper.SetDefault("retention.batch_size", 500)
+
+	viper.SetDefault("calendar.secret", "")
+	viper.SetDefault("calendar.reminder", 24*time.Hour)
+
+	viper.SetDefault("concurrency.require_if_match", true)
+
+	viper.SetDefault("idempotency.ttl", 24*time.Hour)
+	viper.SetDefault("idempotency.cleanup_interval", time.Hour)
+	viper.SetDefault("idempotency.batch_size", 1000)
+
+	viper.SetDefault("openapi.validate_requests", false)
+	viper.SetDefault("openapi.validate_responses", false)
+
+	viper.SetDefault("grpc.addr", ":9090")
+
+	viper.SetDefault("events.buffer_size", 1000)
+	viper.SetDefault("events.keep_alive", 15*time.Second)
+
+	viper.SetDefault("metrics.refresh_interval", time.Minute)
+
+	viper.SetDefault("tracing.exporter", "none")
+	viper.SetDefault("tracing.endpoint", "localhost:4317")
+	viper.SetDefault("tracing.insecure", true)
+	viper.SetDefault("tracing.file", "")
+	viper.SetDefault("tracing.sample_ratio", 1.0)
+	viper.SetDefault("tracing.service_name", "submanager")
+
+	viper.SetDefault("health.timeout", 2*time.Second)
+	viper.SetDefault("health.drain_delay", 0)
+
+	viper.SetDefault("http.max_body_size", 10<<20)
+	viper.SetDefault("http.cors.allowed_origins", []string{})
+	viper.SetDefault("http.cors.allowed_methods", []string{"GET", "POST", "PUT", "PATCH", "DELETE"})
+	viper.SetDefault("http.cors.allowed_headers", []string{
+		"Content-Type", "X-API-Key", "X-Request-ID", "If-Match", "Idempotency-Key", "Last-Event-ID",
+	})
+	viper.SetDefault("http.cors.exposed_headers", []string{
+		"ETag", "Location", "X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After",
+	})
+	viper.SetDefault("http.cors.allow_credentials", false)
+	viper.SetDefault("http.cors.max_age", 10*time.Minute)
+	viper.SetDefault("http.security.content_security_policy",
+		"default-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; "+
+			"frame-ancestors 'none'; base-uri 'self'; form-action 'self'")
+	viper.SetDefault("http.security.hsts_max_age", 365*24*time.Hour)
+
+	viper.SetDefault("rate_limit.enabled", true)
+	viper.SetDefault("rate_limit.store", "memory")
+	viper.SetDefault("rate_limit.redis.addr", "localhost:6379")
+	viper.SetDefault("rate_limit.redis.password", "")
+	viper.SetDefault("rate_limit.redis.db", 0)
+	viper.SetDefault("rate_limit.redis.prefix", "submanager:ratelimit:")
+	viper.SetDefault("rate_limit.requests", 300)
+	viper.SetDefault("rate_limit.period", time.Minute)
+	viper.SetDefault("rate_limit.burst", 50)
+	viper.SetDefault("rate_limit.trusted_proxies", []string{})
+	viper.SetDefault("rate_limit.jwt.hmac_secret", "")
+	viper.SetDefault("rate_limit.jwt.public_key_file", "")
+	viper.SetDefault("rate_limit.jwt.issuer", "")
+	viper.SetDefault("rate_limit.jwt.audience", "")
 
-	if err := viper.ReadInConfig(); err != nil {
-		return fmt.Errorf("error reading config file: %w", err)
+	if path != "" {
+		viper.SetConfigFile(path)
+		if err := viper.ReadInConfig(); err != nil {
+			return fmt.Errorf("error reading config file: %w", err)
+		}
 	}
 
+	// UnmarshalExact не останавливается на первой ошибке: неизвестные ключи, значения
+	// неверного типа и нераскрытые ссылки на секреты попадают в одну ошибку,
+	// а проверка значений дополняет её
 	var cfg Config
-	if err := viper.Unmarshal(&cfg); err != nil {
-		return fmt.Errorf("error unmarshaling config: %w", err)
+	decodeErr := viper.UnmarshalExact(&cfg, viper.DecodeHook(decodeHook()))
+	if err := errors.Join(decodeErr, cfg.loadSecretFiles(), cfg.validate()); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
 	}
 
 	Cfg = &cfg
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"time"
+)
+
+// problems собирает ошибки конфигурации в виде "ключ: сообщение", чтобы при старте
+// увидеть все неверные ключи сразу, а не исправлять их по одному
+type problems []error
+
+func (p *problems) add(key, format string, args ...any) {
+	*p = append(*p, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+}
+
+func (p *problems) positive(key string, d time.Duration) {
+	if d <= 0 {
+		p.add(key, "must be positive, got %s", d)
+	}
+}
+
+func (p *problems) oneOf(key, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		p.add(key, "must be one of %v, got %q", allowed, value)
+	}
+}
+
+func (c *Config) validate() error {
+	var p problems
+
+	if _, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		p.add("server.addr", "%v", err)
+	}
+	p.positive("server.read_timeout", c.Server.ReadTimeout)
+	p.positive("server.write_timeout", c.Server.WriteTimeout)
+	p.positive("server.idle_timeout", c.Server.IdleTimeout)
+	p.positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+
+	p.oneOf("logging.level", c.Logging.Level, "debug", "info", "warn", "error")
+	p.oneOf("logging.format", c.Logging.Format, "json", "console")
+
+	if c.DB.URL != "" {
+		// строка подключения используется как есть: пароль и TLS задаются в ней самой
+		password := c.DB.Password
+		if c.DB.PasswordFile != "" {
+			password = ""
+		}
+		for _, setting := range []struct{ key, value string }{
+			{"db.password", password},
+			{"db.password_file", c.DB.PasswordFile},
+			{"db.tls.sslmode", c.DB.TLS.SSLMode},
+			{"db.tls.root_cert", c.DB.TLS.RootCert},
+			{"db.tls.cert", c.DB.TLS.Cert},
+			{"db.tls.key", c.DB.TLS.Key},
+		} {
+			if setting.value != "" {
+				p.add(setting.key, "must not be set together with db.url; put it into the url")
+			}
+		}
+	} else {
+		if c.DB.Host == "" {
+			p.add("db.host", "is required unless db.url is set")
+		}
+		if c.DB.Port <= 0 || c.DB.Port > 65535 {
+			p.add("db.port", "must be in 1..65535, got %d", c.DB.Port)
+		}
+		if c.DB.Name == "" {
+			p.add("db.name", "is required unless db.url is set")
+		}
+	}
+	p.oneOf("db.tls.sslmode", c.DB.TLS.SSLMode, "", "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	if (c.DB.TLS.Cert == "") != (c.DB.TLS.Key == "") {
+		p.add("db.tls", "cert and key must be set together")
+	}
+	if c.DB.Pool.MaxOpenConns <= 0 {
+		p.add("db.pool.max_open_conns", "must be positive, got %d", c.DB.Pool.MaxOpenConns)
+	}
+	if c.DB.Pool.MaxIdleConns < 0 || c.DB.Pool.MaxIdleConns > c.DB.Pool.MaxOpenConns {
+		p.add("db.pool.max_idle_conns", "must be in 0..max_open_conns, got %d", c.DB.Pool.MaxIdleConns)
+	}
+	if c.DB.Pool.ConnMaxLifetime < 0 {
+		p.add("db.pool.conn_max_lifetime", "must not be negative")
+	}
+	if c.DB.Pool.ConnMaxIdleTime < 0 {
+		p.add("db.pool.conn_max_idle_time", "must not be negative")
+	}
+
+	p.oneOf("outbox.publisher", c.Outbox.Publisher, "", "log", "http", "nats")
+	if c.Outbox.Publisher == "http" && c.Outbox.HTTPURL == "" {
+		p.add("outbox.http_url", "is required for http publisher")
+	}
+	p.positive("outbox.poll_interval", c.Outbox.PollInterval)
+	if c.Outbox.BatchSize <= 0 {
+		p.add("outbox.batch_size", "must be positive, got %d", c.Outbox.BatchSize)
+	}
+	p.positive("outbox.publish_timeout", c.Outbox.PublishTimeout)
+	if c.Outbox.MaxAttempts <= 0 {
+		p.add("outbox.max_attempts", "must be positive, got %d", c.Outbox.MaxAttempts)
+	}
+	p.positive("outbox.retry_backoff", c.Outbox.RetryBackoff)
+	if c.Outbox.MaxRetryBackoff < c.Outbox.RetryBackoff {
+		p.add("outbox.max_retry_backoff", "must not be less than retry_backoff")
+	}
+	if c.Outbox.SentRetention < 0 {
+		p.add("outbox.sent_retention", "must not be negative")
+	}
+	if c.Outbox.SentRetention > 0 {
+		p.positive("outbox.cleanup_interval", c.Outbox.CleanupInterval)
+		if c.Outbox.CleanupBatchSize <= 0 {
+			p.add("outbox.cleanup_batch_size", "must be positive, got %d", c.Outbox.CleanupBatchSize)
+		}
+	}
+
+	if c.Retention.DeletedSubscriptions < 0 {
+		p.add("retention.deleted_subscriptions", "must not be negative")
+	}
+	if c.Retention.DeletedSubscriptions > 0 {
+		p.positive("retention.purge_interval", c.Retention.PurgeInterval)
+		if c.Retention.BatchSize <= 0 {
+			p.add("retention.batch_size", "must be positive, got %d", c.Retention.BatchSize)
+		}
+	}
+
+	p.positive("calendar.reminder", c.Calendar.Reminder)
+
+	p.positive("idempotency.ttl", c.Idempotency.TTL)
+	p.positive("idempotency.cleanup_interval", c.Idempotency.CleanupInterval)
+	if c.Idempotency.BatchSize <= 0 {
+		p.add("idempotency.batch_size", "must be positive, got %d", c.Idempotency.BatchSize)
+	}
+
+	if c.Events.BufferSize < 0 {
+		p.add("events.buffer_size", "must not be negative")
+	}
+	p.positive("events.keep_alive", c.Events.KeepAlive)
+
+	p.positive("metrics.refresh_interval", c.Metrics.RefreshInterval)
+
+	for i, key := range c.Auth.APIKeys {
+		if key.Key == "" {
+			p.add(fmt.Sprintf("auth.api_keys[%d].key", i), "is required")
+		}
+	}
+
+	p.oneOf("tracing.exporter", c.Tracing.Exporter, "", "none", "otlp", "stdout")
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		p.add("tracing.sample_ratio", "must be in [0, 1], got %v", c.Tracing.SampleRatio)
+	}
+
+	p.positive("health.timeout", c.Health.Timeout)
+	if c.HTTP.MaxBodySize < 0 {
+		p.add("http.max_body_size", "must not be negative")
+	}
+
+	if c.RateLimit.Enabled {
+		p.oneOf("rate_limit.store", c.RateLimit.Store, "memory", "redis")
+		if c.RateLimit.Store == "redis" {
+			if _, _, err := net.SplitHostPort(c.RateLimit.Redis.Addr); err != nil {
+				p.add("rate_limit.redis.addr", "%v", err)
+			}
+			if c.RateLimit.Redis.DB < 0 {
+				p.add("rate_limit.redis.db", "must not be negative")
+			}
+		}
+		if c.RateLimit.Requests < 0 {
+			p.add("rate_limit.requests", "must not be negative")
+		}
+		if c.RateLimit.Burst < 0 {
+			p.add("rate_limit.burst", "must not be negative")
+		}
+		p.positive("rate_limit.period", c.RateLimit.Period)
+		if c.RateLimit.JWT.HMACSecret != "" && c.RateLimit.JWT.PublicKeyFile != "" {
+			p.add("rate_limit.jwt", "hmac_secret and public_key_file are mutually exclusive")
+		}
+		for i, rule := range c.RateLimit.Routes {
+			key := fmt.Sprintf("rate_limit.routes[%d]", i)
+			if rule.Route == "" {
+				p.add(key+".route", "is required")
+			}
+			if rule.Requests < 0 {
+				p.add(key+".requests", "must not be negative")
+			}
+			if rule.Burst < 0 {
+				p.add(key+".burst", "must not be negative")
+			}
+			if rule.Period < 0 {
+				p.add(key+".period", "must not be negative")
+			}
+		}
+	}
+
+	return errors.Join(p...)
+}
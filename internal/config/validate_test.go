@@ -0,0 +1,173 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// defaults конфигурация по умолчанию, загруженная так же, как при запуске без файла
+func defaults(t *testing.T) Config {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	if err := Init(""); err != nil {
+		t.Fatalf("defaults are invalid: %v", err)
+	}
+	return *Cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(c *Config)
+		keys   []string
+	}{
+		{"defaults", func(c *Config) {}, nil},
+		{"bad server addr", func(c *Config) { c.Server.Addr = "8080" }, []string{"server.addr"}},
+		{"zero timeouts", func(c *Config) {
+			c.Server.ReadTimeout = 0
+			c.Server.WriteTimeout = -time.Second
+		}, []string{"server.read_timeout", "server.write_timeout"}},
+		{"unknown log level", func(c *Config) { c.Logging.Level = "trace" }, []string{"logging.level"}},
+		{"db without host", func(c *Config) { c.DB.Host = "" }, []string{"db.host"}},
+		{"db port out of range", func(c *Config) { c.DB.Port = 70000 }, []string{"db.port"}},
+		{"db url replaces host", func(c *Config) {
+			c.DB.URL = "postgres://u:p@db/app"
+			c.DB.Host = ""
+		}, nil},
+		{"db url with password and tls", func(c *Config) {
+			c.DB.URL = "postgres://u@db/app"
+			c.DB.Password = "secret"
+			c.DB.TLS.SSLMode = "verify-full"
+			c.DB.TLS.RootCert = "/ca.pem"
+		}, []string{"db.password", "db.tls.sslmode", "db.tls.root_cert"}},
+		{"db url with password file", func(c *Config) {
+			c.DB.URL = "postgres://u@db/app"
+			c.DB.PasswordFile = "/run/secrets/db"
+			c.DB.Password = "read from the file"
+		}, []string{"db.password_file"}},
+		{"unknown sslmode", func(c *Config) { c.DB.TLS.SSLMode = "on" }, []string{"db.tls.sslmode"}},
+		{"cert without key", func(c *Config) { c.DB.TLS.Cert = "/client.pem" }, []string{"db.tls"}},
+		{"idle above open conns", func(c *Config) { c.DB.Pool.MaxIdleConns = c.DB.Pool.MaxOpenConns + 1 }, []string{"db.pool.max_idle_conns"}},
+		{"http publisher without url", func(c *Config) { c.Outbox.Publisher = "http" }, []string{"outbox.http_url"}},
+		{"outbox backoff", func(c *Config) {
+			c.Outbox.RetryBackoff = time.Minute
+			c.Outbox.MaxRetryBackoff = time.Second
+		}, []string{"outbox.max_retry_backoff"}},
+		{"outbox cleanup", func(c *Config) {
+			c.Outbox.CleanupInterval = 0
+			c.Outbox.CleanupBatchSize = 0
+		}, []string{"outbox.cleanup_interval", "outbox.cleanup_batch_size"}},
+		{"outbox cleanup off", func(c *Config) {
+			c.Outbox.SentRetention = 0
+			c.Outbox.CleanupInterval = 0
+		}, nil},
+		{"empty api key", func(c *Config) { c.Auth.APIKeys = []APIKey{{Key: "k"}, {Actor: "bot"}} }, []string{"auth.api_keys[1].key"}},
+		{"retention worker", func(c *Config) {
+			c.Retention.PurgeInterval = 0
+			c.Retention.BatchSize = -1
+		}, []string{"retention.purge_interval", "retention.batch_size"}},
+		{"retention off", func(c *Config) {
+			c.Retention.DeletedSubscriptions = 0
+			c.Retention.PurgeInterval = 0
+		}, nil},
+		{"calendar reminder", func(c *Config) { c.Calendar.Reminder = 0 }, []string{"calendar.reminder"}},
+		{"idempotency", func(c *Config) {
+			c.Idempotency.TTL = 0
+			c.Idempotency.CleanupInterval = -time.Minute
+			c.Idempotency.BatchSize = 0
+		}, []string{"idempotency.ttl", "idempotency.cleanup_interval", "idempotency.batch_size"}},
+		{"events", func(c *Config) {
+			c.Events.BufferSize = -1
+			c.Events.KeepAlive = 0
+		}, []string{"events.buffer_size", "events.keep_alive"}},
+		{"unbuffered events", func(c *Config) { c.Events.BufferSize = 0 }, nil},
+		{"metrics refresh", func(c *Config) { c.Metrics.RefreshInterval = 0 }, []string{"metrics.refresh_interval"}},
+		{"sample ratio", func(c *Config) { c.Tracing.SampleRatio = 1.5 }, []string{"tracing.sample_ratio"}},
+		{"rate limit store", func(c *Config) { c.RateLimit.Store = "memcached" }, []string{"rate_limit.store"}},
+		{"redis addr", func(c *Config) {
+			c.RateLimit.Store = "redis"
+			c.RateLimit.Redis.Addr = "redis"
+		}, []string{"rate_limit.redis.addr"}},
+		{"both jwt keys", func(c *Config) {
+			c.RateLimit.JWT.HMACSecret = "s"
+			c.RateLimit.JWT.PublicKeyFile = "/key.pem"
+		}, []string{"rate_limit.jwt"}},
+		{"route rule", func(c *Config) {
+			c.RateLimit.Routes = []RateLimitRule{{Requests: -1}}
+		}, []string{"rate_limit.routes[0].route", "rate_limit.routes[0].requests"}},
+		{"rate limit off skips its keys", func(c *Config) {
+			c.RateLimit.Enabled = false
+			c.RateLimit.Store = "memcached"
+			c.RateLimit.Period = 0
+		}, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := defaults(t)
+			tc.mutate(&cfg)
+
+			err := cfg.validate()
+			if len(tc.keys) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("accepted, want errors for %v", tc.keys)
+			}
+
+			lines := strings.Split(err.Error(), "\n")
+			if len(lines) != len(tc.keys) {
+				t.Errorf("got %d errors, want %d:\n%v", len(lines), len(tc.keys), err)
+			}
+			for _, key := range tc.keys {
+				found := false
+				for _, line := range lines {
+					if strings.HasPrefix(line, key+": ") {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("no error for %s in:\n%v", key, err)
+				}
+			}
+		})
+	}
+}
+
+func TestInitReportsEveryProblem(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	t.Setenv("SUBMANAGER_SERVER_READ_TIMEOUT", "0s")
+	t.Setenv("SUBMANAGER_LOGGING_LEVEL", "loud")
+	t.Setenv("SUBMANAGER_DB_PORT", "not-a-number")
+
+	err := Init("")
+	if err == nil {
+		t.Fatal("invalid environment accepted")
+	}
+	for _, want := range []string{"server.read_timeout", "logging.level", "port"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestConfigFilesAreValid(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "admin-key")
+	t.Setenv("CALENDAR_SECRET", "calendar-secret")
+
+	for _, path := range []string{"../../config/config.dev.yaml", "../../config/config.docker.yaml"} {
+		viper.Reset()
+		if err := Init(path); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+	}
+	viper.Reset()
+}
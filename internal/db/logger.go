@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+const slowQueryThreshold = 200 * time.Millisecond
+
+// queryLogger пишет ошибки и медленные запросы GORM через логгер запроса из контекста,
+// чтобы они попадали в лог с request_id и trace_id, а не в stdout мимо zap
+type queryLogger struct {
+	level gormlogger.LogLevel
+}
+
+func newQueryLogger() gormlogger.Interface {
+	return &queryLogger{level: gormlogger.Warn}
+}
+
+func (l *queryLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &queryLogger{level: level}
+}
+
+func (l *queryLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Info {
+		logger.FromContext(ctx).Info(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *queryLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Warn {
+		logger.FromContext(ctx).Warn(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *queryLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.level >= gormlogger.Error {
+		logger.FromContext(ctx).Error(fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *queryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= gormlogger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && l.level >= gormlogger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		sql, rows := fc()
+		logger.FromContext(ctx).Error("query failed",
+			zap.String("sql", sql),
+			zap.Int64("rows", rows),
+			zap.Duration("duration", elapsed),
+			zap.Error(err),
+		)
+	case elapsed > slowQueryThreshold && l.level >= gormlogger.Warn:
+		sql, rows := fc()
+		logger.FromContext(ctx).Warn("slow query",
+			zap.String("sql", sql),
+			zap.Int64("rows", rows),
+			zap.Duration("duration", elapsed),
+		)
+	case l.level >= gormlogger.Info:
+		sql, rows := fc()
+		logger.FromContext(ctx).Debug("query",
+			zap.String("sql", sql),
+			zap.Int64("rows", rows),
+			zap.Duration("duration", elapsed),
+		)
+	}
+}
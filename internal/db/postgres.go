@@ -3,7 +3,7 @@ package db
 import (
 	"context"
 	"fmt"
-	"time"
+	"strings"
 
 	"github.com/BabichevDima/subManager/internal/config"
 	"github.com/BabichevDima/subManager/internal/models"
@@ -14,16 +14,64 @@ import (
 	"gorm.io/driver/postgres"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// SchemaVersion версия схемы, которую ожидает приложение. Увеличивается при каждом
+// изменении моделей или SQL миграций, чтобы /readyz не пускал трафик на базу со
+// старой схемой. Миграции только добавляют, поэтому более новая схема совместима.
+const SchemaVersion = 3
+
+// auditAppendOnlySQL запрещает изменение и удаление записей журнала на уровне БД
+var auditAppendOnlySQL = []string{
+	`CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_log is append-only';
+	END;
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log`,
+	`CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+	FOR EACH ROW EXECUTE FUNCTION audit_log_append_only()`,
+}
+
+// DSN строка подключения: db.url как есть или собранная из отдельных параметров
+// вместе с настройками TLS. Пароль и TLS вместе с db.url отклоняет проверка конфигурации
+func DSN(cfg config.DBConfig) string {
+	if cfg.URL != "" {
+		return cfg.URL
+	}
+
+	sslMode := cfg.TLS.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	params := []string{
+		"host=" + dsnValue(cfg.Host),
+		fmt.Sprintf("port=%d", cfg.Port),
+		"user=" + dsnValue(cfg.User),
+		"password=" + dsnValue(cfg.Password),
+		"dbname=" + dsnValue(cfg.Name),
+		"sslmode=" + dsnValue(sslMode),
+	}
+	if cfg.TLS.RootCert != "" {
+		params = append(params, "sslrootcert="+dsnValue(cfg.TLS.RootCert))
+	}
+	if cfg.TLS.Cert != "" {
+		params = append(params, "sslcert="+dsnValue(cfg.TLS.Cert), "sslkey="+dsnValue(cfg.TLS.Key))
+	}
+	return strings.Join(params, " ")
+}
+
+// dsnValue берёт значение в кавычки, чтобы пароль с пробелами или кавычками не ломал DSN
+func dsnValue(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
 func InitPostgres(cfg config.DBConfig) (*gorm.DB, error) {
-	dsn := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name,
-	)
+	dsn := DSN(cfg)
 
-	logger.Info(dsn)
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	logger.Info("Connecting to the database", zap.String("dsn", config.RedactDSN(dsn)))
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: newQueryLogger()})
 	if err != nil {
 		return nil, err
 	}
@@ -37,17 +85,60 @@ func InitPostgres(cfg config.DBConfig) (*gorm.DB, error) {
 		logger.Fatal("db ping failed", zap.Error(err))
 	}
 
-	sqlDB.SetMaxOpenConns(10)
-	sqlDB.SetMaxIdleConns(5)
-	sqlDB.SetConnMaxLifetime(time.Hour)
+	sqlDB.SetMaxOpenConns(cfg.Pool.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.Pool.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.Pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.Pool.ConnMaxIdleTime)
 
-	if err := db.AutoMigrate(&models.Subscription{}); err != nil {
+	if err := db.AutoMigrate(&models.Subscription{}, &models.OutboxMessage{}, &models.AuditEntry{}, &models.IdempotencyKey{}, &models.SchemaMigration{}); err != nil {
+		logger.Fatal("db migration failed", zap.Error(err))
+	}
+
+	for _, stmt := range auditAppendOnlySQL {
+		if err := db.Exec(stmt).Error; err != nil {
+			logger.Fatal("db migration failed", zap.Error(err))
+		}
+	}
+
+	// версия записывается последней: база с ней прошла все миграции выше
+	migration := models.SchemaMigration{Version: SchemaVersion}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&migration).Error; err != nil {
 		logger.Fatal("db migration failed", zap.Error(err))
 	}
 
 	return db, nil
 }
 
+// Ping проверяет, что база отвечает
+func Ping(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// CheckSchema проверяет, что схема базы не старше SchemaVersion. Более новую схему
+// приносит следующая версия при поэтапном обновлении, и старые экземпляры должны
+// продолжать обслуживать трафик, пока их не заменят
+func CheckSchema(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var version int
+		err := db.WithContext(ctx).Model(&models.SchemaMigration{}).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&version).Error
+		if err != nil {
+			return err
+		}
+		if version < SchemaVersion {
+			return fmt.Errorf("schema version %d, expected at least %d", version, SchemaVersion)
+		}
+		return nil
+	}
+}
+
 func ShutdownDB(db *gorm.DB) func(ctx context.Context) error {
 	return func(ctx context.Context) error {
 		sqlDB, err := db.DB()
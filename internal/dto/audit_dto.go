@@ -0,0 +1,63 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/models"
+	"github.com/google/uuid"
+)
+
+// AuditEntryResponse запись журнала изменений подписки
+type AuditEntryResponse struct {
+	ID             uuid.UUID       `json:"id"`
+	SubscriptionID uuid.UUID       `json:"subscription_id"`
+	Actor          string          `json:"actor"`
+	Action         string          `json:"action"`
+	Before         json.RawMessage `json:"before,omitempty"`
+	After          json.RawMessage `json:"after,omitempty"`
+	Diff           json.RawMessage `json:"diff"`
+	RequestID      string          `json:"request_id,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// AuditFieldChange изменение одного поля в diff
+type AuditFieldChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditLogRequest фильтры журнала изменений из query-параметров
+type AuditLogRequest struct {
+	Actor string `json:"actor"`
+	From  string `json:"from" validate:"timestamp"`
+	To    string `json:"to" validate:"timestamp"`
+}
+
+// AuditFilter фильтры журнала изменений
+type AuditFilter struct {
+	SubscriptionID *uuid.UUID
+	Actor          string
+	From           *time.Time
+	To             *time.Time
+}
+
+// AuditListResponse - структура для ответа со списком записей журнала
+type AuditListResponse struct {
+	Data       []AuditEntryResponse `json:"data"`
+	Pagination PaginationResponse   `json:"pagination"`
+}
+
+func AuditFromModel(entry *models.AuditEntry) AuditEntryResponse {
+	return AuditEntryResponse{
+		ID:             entry.ID,
+		SubscriptionID: entry.SubscriptionID,
+		Actor:          entry.Actor,
+		Action:         entry.Action,
+		Before:         entry.Before,
+		After:          entry.After,
+		Diff:           entry.Diff,
+		RequestID:      entry.RequestID,
+		CreatedAt:      entry.CreatedAt,
+	}
+}
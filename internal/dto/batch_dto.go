@@ -0,0 +1,57 @@
+package dto
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// BatchOperation операция пакетного запроса
+type BatchOperation struct {
+	Op   string          `json:"op" enums:"create,update,delete" validate:"required,oneof=create update delete"`
+	ID   string          `json:"id,omitempty" validate:"uuid"`
+	Data json.RawMessage `json:"data,omitempty"`
+	// Version ожидаемая версия подписки для update и delete, аналог If-Match
+	Version *int64 `json:"version,omitempty"`
+}
+
+// BatchItem разобранная операция пакета. Если Invalid не nil,
+// операция не выполняется и считается ошибкой валидации.
+type BatchItem struct {
+	Op      string
+	ID      string
+	Create  RequestSubscription
+	Update  UpdateSubscriptionRequest
+	IfMatch IfMatch
+	Invalid error
+}
+
+// BatchOutcome результат выполнения одной операции пакета
+type BatchOutcome struct {
+	Subscription *ResponseSubscription
+	Err          error
+}
+
+// BatchItemResult результат операции в ответе
+type BatchItemResult struct {
+	Index  int                   `json:"index"`
+	Op     string                `json:"op"`
+	Status int                   `json:"status"`
+	ID     *uuid.UUID            `json:"id,omitempty"`
+	Data   *ResponseSubscription `json:"data,omitempty"`
+	Error  *Problem              `json:"error,omitempty"`
+}
+
+// BatchResponse ответ на пакетный запрос
+type BatchResponse struct {
+	Atomic    bool              `json:"atomic"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BatchItemResult `json:"results"`
+}
@@ -0,0 +1,109 @@
+package dto
+
+import (
+	"errors"
+	"strings"
+)
+
+// Error доменная ошибка со стабильным кодом. Код попадает в поле code ответа
+// application/problem+json, и клиенты могут на него полагаться; текст может меняться.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	ErrSubscriptionExists   = &Error{Code: "subscription_exists", Message: "subscription already exists"}
+	ErrInvalidID            = &Error{Code: "invalid_id", Message: "invalid ID format"}
+	ErrInvalidFormat        = &Error{Code: "invalid_format", Message: "invalid format"}
+	ErrRecordNotFound       = &Error{Code: "subscription_not_found", Message: "subscription not found or already deleted"}
+	ErrNotDeleted           = &Error{Code: "subscription_not_deleted", Message: "subscription is not deleted"}
+	ErrPreconditionFailed   = &Error{Code: "precondition_failed", Message: "subscription was modified by another request; fetch it again to get the current ETag"}
+	ErrPreconditionRequired = &Error{Code: "precondition_required", Message: "If-Match header with the subscription ETag is required"}
+	ErrValidation           = &Error{Code: "validation_failed", Message: "validation failed"}
+	ErrBatchAborted         = &Error{Code: "batch_aborted", Message: "not applied: another operation in the atomic batch failed"}
+
+	ErrInvalidBody          = &Error{Code: "invalid_body", Message: "invalid request payload"}
+	ErrBodyTooLarge         = &Error{Code: "body_too_large", Message: "request body is too large"}
+	ErrUnsupportedMediaType = &Error{Code: "unsupported_media_type", Message: "unsupported Content-Type"}
+	ErrNotAcceptable        = &Error{Code: "not_acceptable", Message: "requested format is not supported"}
+	ErrUnauthorized         = &Error{Code: "unauthorized", Message: "invalid API key"}
+	ErrForbidden            = &Error{Code: "forbidden", Message: "admin privileges required"}
+	ErrInvalidToken         = &Error{Code: "invalid_token", Message: "invalid calendar token"}
+	ErrCalendarDisabled     = &Error{Code: "calendar_disabled", Message: "renewals calendar is disabled"}
+	ErrIdempotencyKeyReused = &Error{Code: "idempotency_key_reused", Message: "Idempotency-Key has already been used for a different request"}
+	ErrIdempotencyKeyInUse  = &Error{Code: "idempotency_key_in_use", Message: "a request with this Idempotency-Key is still being processed"}
+	ErrRateLimited          = &Error{Code: "rate_limited", Message: "too many requests; retry after the time in the Retry-After header"}
+	ErrQueryTooComplex      = &Error{Code: "query_too_complex", Message: "GraphQL query is too deep or requests too many values"}
+	ErrInternal             = &Error{Code: "internal_error", Message: "internal server error"}
+)
+
+// FieldError ошибка значения одного поля запроса
+type FieldError struct {
+	Field   string `json:"field" example:"price"`
+	Message string `json:"message" example:"must be positive"`
+}
+
+// ValidationError ошибки значений полей запроса. errors.Is(err, ErrValidation)
+// для неё истинно, так что её можно проверять так же, как остальные доменные ошибки.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// InvalidField возвращает ошибку валидации одного поля
+func InvalidField(field, message string) *ValidationError {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+// Add добавляет ошибку поля
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// Err возвращает nil, если ошибок полей нет
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Field + ": " + field.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// Code возвращает стабильный код ошибки или пустую строку для ошибок вне домена
+func Code(err error) string {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr.Code
+	}
+	if errors.Is(err, ErrValidation) {
+		return ErrValidation.Code
+	}
+	return ""
+}
+
+// Problem тело ответа об ошибке по RFC 7807 (application/problem+json)
+type Problem struct {
+	Type     string       `json:"type" example:"urn:submanager:problem:validation_failed"`
+	Title    string       `json:"title" example:"Bad Request"`
+	Status   int          `json:"status" example:"400"`
+	Code     string       `json:"code" example:"validation_failed"`
+	Detail   string       `json:"detail,omitempty" example:"price: must be positive"`
+	Instance string       `json:"instance,omitempty" example:"6f1c2a7e-3b9d-4c51-9a0e-2f4d8b7c1e55"`
+	TraceID  string       `json:"trace_id,omitempty" example:"4bf92f3577b34da6a3ce929d0e0e4736"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
@@ -0,0 +1,27 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventSubscriptionCreated  = "subscription.created"
+	EventSubscriptionUpdated  = "subscription.updated"
+	EventSubscriptionDeleted  = "subscription.deleted"
+	EventSubscriptionRestored = "subscription.restored"
+)
+
+// EventStreamRequest фильтры потока событий
+type EventStreamRequest struct {
+	UserID string `json:"user_id" validate:"uuid"`
+}
+
+// SubscriptionEvent событие об изменении подписки, публикуемое через outbox и шину событий
+type SubscriptionEvent struct {
+	ID           uuid.UUID            `json:"id"`
+	Type         string               `json:"type"`
+	Subscription ResponseSubscription `json:"subscription"`
+	OccurredAt   time.Time            `json:"occurred_at"`
+}
@@ -0,0 +1,46 @@
+package dto
+
+import "github.com/google/uuid"
+
+const (
+	ImportStatusCreated   = "created"
+	ImportStatusDuplicate = "duplicate"
+	ImportStatusInvalid   = "invalid"
+)
+
+// ImportRow строка CSV-файла после разбора и валидации.
+// Если Error не пустой, строка не импортируется.
+type ImportRow struct {
+	Line    int
+	Request RequestSubscription
+	Error   string
+}
+
+// ImportRowResult результат импорта одной строки
+type ImportRowResult struct {
+	Line   int        `json:"line"`
+	Status string     `json:"status" enums:"created,duplicate,invalid"`
+	ID     *uuid.UUID `json:"id,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// ImportReport отчёт об импорте подписок
+type ImportReport struct {
+	DryRun     bool              `json:"dry_run"`
+	Created    int               `json:"created"`
+	Duplicates int               `json:"skipped_duplicates"`
+	Invalid    int               `json:"invalid"`
+	Rows       []ImportRowResult `json:"rows"`
+}
+
+func (r *ImportReport) Add(result ImportRowResult) {
+	switch result.Status {
+	case ImportStatusCreated:
+		r.Created++
+	case ImportStatusDuplicate:
+		r.Duplicates++
+	case ImportStatusInvalid:
+		r.Invalid++
+	}
+	r.Rows = append(r.Rows, result)
+}
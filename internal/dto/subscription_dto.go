@@ -1,7 +1,8 @@
 package dto
 
 import (
-	"errors"
+	"bytes"
+	"encoding/json"
 	"time"
 
 	"github.com/BabichevDima/subManager/internal/models"
@@ -10,32 +11,26 @@ import (
 
 // RequestSubscription для создания подписки
 type RequestSubscription struct {
-	ServiceName string `json:"service_name" binding:"required"`
-	Price       int    `json:"price" binding:"required,gt=0"`
-	UserID      string `json:"user_id" binding:"required,uuid"`
-	StartDate   string `json:"start_date" binding:"required"`
-	EndDate     string `json:"end_date,omitempty"`
+	ServiceName string `json:"service_name" validate:"required,max=100"`
+	Price       int    `json:"price" validate:"required,gt=0"`
+	UserID      string `json:"user_id" validate:"required,uuid"`
+	StartDate   string `json:"start_date" validate:"required,mmyyyy"`
+	EndDate     string `json:"end_date,omitempty" validate:"mmyyyy,gtefield=StartDate"`
 }
 
 // ResponseSubscription для ответа с подпиской
 type ResponseSubscription struct {
-	ID          uuid.UUID `json:"id"`
-	ServiceName string    `json:"service_name"`
-	Price       int       `json:"price"`
-	UserID      uuid.UUID `json:"user_id"`
-	StartDate   string    `json:"start_date"`
-	EndDate     *string   `json:"end_date,omitempty"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-}
-
-var (
-	ErrSubscriptionExists   = errors.New("subscription already exists")
-	ErrSubscriptionNotFound = errors.New("subscription not found")
-	ErrInvalidID            = errors.New("invalid ID format")
-	ErrInvalidFormat        = errors.New("invalid format")
-	ErrRecordNotFound       = errors.New("subscription not found or already deleted")
-)
+	ID          uuid.UUID  `json:"id"`
+	ServiceName string     `json:"service_name"`
+	Price       int        `json:"price"`
+	UserID      uuid.UUID  `json:"user_id"`
+	StartDate   string     `json:"start_date"`
+	EndDate     *string    `json:"end_date,omitempty"`
+	Version     int64      `json:"version"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+}
 
 func FromModel(sub *models.Subscription) ResponseSubscription {
 	response := ResponseSubscription{
@@ -44,6 +39,7 @@ func FromModel(sub *models.Subscription) ResponseSubscription {
 		Price:       sub.Price,
 		UserID:      sub.UserID,
 		StartDate:   sub.StartDate.Format("01-2006"),
+		Version:     sub.Version,
 		CreatedAt:   sub.CreatedAt,
 		UpdatedAt:   sub.UpdatedAt,
 	}
@@ -53,22 +49,169 @@ func FromModel(sub *models.Subscription) ResponseSubscription {
 		response.EndDate = &endDateStr
 	}
 
+	if sub.DeletedAt.Valid {
+		deletedAt := sub.DeletedAt.Time
+		response.DeletedAt = &deletedAt
+	}
+
 	return response
 }
 
-// UpdateSubscriptionRequest для обновления подписки
+// UpdateSubscriptionRequest частичное обновление подписки в формате JSON Merge Patch (RFC 7396):
+// отсутствующее поле не меняется, null очищает значение
 type UpdateSubscriptionRequest struct {
-	ServiceName string `json:"service_name"`
-	Price       int    `json:"price"`
-	EndDate     string `json:"end_date"`
+	ServiceName OptionalString `json:"service_name,omitzero" validate:"notnull,nonempty,max=100"`
+	Price       OptionalInt    `json:"price,omitzero" validate:"notnull,gt=0"`
+	UserID      OptionalString `json:"user_id,omitzero" validate:"notnull,nonempty,uuid"`
+	StartDate   OptionalString `json:"start_date,omitzero" validate:"notnull,nonempty,mmyyyy"`
+	EndDate     OptionalString `json:"end_date,omitzero" validate:"mmyyyy,gtefield=StartDate"`
+}
+
+// Empty сообщает, что патч не содержит ни одного поля
+func (r UpdateSubscriptionRequest) Empty() bool {
+	return !r.ServiceName.Set && !r.Price.Set && !r.UserID.Set && !r.StartDate.Set && !r.EndDate.Set
+}
+
+// Check запрещает пустой патч
+func (r UpdateSubscriptionRequest) Check(errs *ValidationError) {
+	if r.Empty() {
+		errs.Add("body", "at least one field must be provided")
+	}
+}
+
+// OptionalString поле патча, различающее отсутствие значения и явный null
+type OptionalString struct {
+	Set   bool
+	Null  bool
+	Value string
+}
+
+func (o *OptionalString) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if bytes.Equal(data, []byte("null")) {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// MarshalJSON записывает null для сброшенного поля; непереданное поле
+// пропускается благодаря omitzero в теге
+func (o OptionalString) MarshalJSON() ([]byte, error) {
+	if o.Null {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.Value)
+}
+
+// OptionalValue возвращает значение поля для проверки по тегам validate
+func (o OptionalString) OptionalValue() (interface{}, bool, bool) {
+	return o.Value, o.Set, o.Null
+}
+
+// OptionalInt поле патча, различающее отсутствие значения и явный null
+type OptionalInt struct {
+	Set   bool
+	Null  bool
+	Value int
+}
+
+func (o *OptionalInt) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if bytes.Equal(data, []byte("null")) {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// MarshalJSON записывает null для сброшенного поля; непереданное поле
+// пропускается благодаря omitzero в теге
+func (o OptionalInt) MarshalJSON() ([]byte, error) {
+	if o.Null {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.Value)
+}
+
+// OptionalValue возвращает значение поля для проверки по тегам validate
+func (o OptionalInt) OptionalValue() (interface{}, bool, bool) {
+	return o.Value, o.Set, o.Null
+}
+
+// IfMatch версии подписки из заголовка If-Match. nil означает отсутствие условия
+// (заголовка нет или он равен *), пустой список не совпадает ни с одной версией.
+type IfMatch []int64
+
+// Allows сообщает, удовлетворяет ли версия условию
+func (m IfMatch) Allows(version int64) bool {
+	if m == nil {
+		return true
+	}
+	for _, v := range m {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscriptionListRequest фильтры списка подписок из query-параметров
+type SubscriptionListRequest struct {
+	UserID         string `json:"user_id" validate:"uuid"`
+	ServiceName    string `json:"service_name" validate:"max=100"`
+	IncludeDeleted bool   `json:"include_deleted"`
+}
+
+// Поля, по которым можно упорядочить список подписок
+const (
+	SortCreatedAt   = "created_at"
+	SortPrice       = "price"
+	SortStartDate   = "start_date"
+	SortServiceName = "service_name"
+)
+
+// SubscriptionSort порядок списка подписок; пустое поле оставляет порядок базы
+type SubscriptionSort struct {
+	Field string
+	Desc  bool
+}
+
+// SubscriptionFilter фильтры выборки подписок
+type SubscriptionFilter struct {
+	UserID         *uuid.UUID
+	ServiceName    string
+	IncludeDeleted bool
+	Sort           SubscriptionSort
+}
+
+// TotalCostFilter фильтры подписок, входящих в расчёт стоимости
+type TotalCostFilter struct {
+	UserIDs     []uuid.UUID
+	ServiceName string
+	StartDate   time.Time
+	EndDate     time.Time
 }
 
 // TotalCostRequest для подсчета стоимости подписок
 type TotalCostRequest struct {
-	UserID      string `json:"user_id"`
-	ServiceName string `json:"service_name"`
-	StartDate   string `json:"start_date"`
-	EndDate     string `json:"end_date"`
+	UserID      string `json:"user_id" validate:"required,uuid"`
+	ServiceName string `json:"service_name" validate:"max=100"`
+	StartDate   string `json:"start_date" validate:"required,mmyyyy"`
+	EndDate     string `json:"end_date" validate:"required,mmyyyy,gtefield=StartDate"`
+}
+
+// UsersTotalCostRequest подсчёт стоимости подписок нескольких пользователей за один период
+type UsersTotalCostRequest struct {
+	UserIDs     []string
+	ServiceName string
+	StartDate   string
+	EndDate     string
+}
+
+// CalendarLinkResponse ссылка на календарь продлений пользователя
+type CalendarLinkResponse struct {
+	URL string `json:"url"`
 }
 
 // TotalCostResponse для ответа стоимости подписок
@@ -77,11 +220,6 @@ type TotalCostResponse struct {
 	SubscriptionsCount int     `json:"subscriptions_count"`
 }
 
-// ErrorResponse представляет структуру ошибки API
-type ErrorResponse struct {
-	Error string `json:"error"`
-}
-
 // SubscriptionListResponse - структура для ответа со списком подписок
 type SubscriptionListResponse struct {
 	Data       []ResponseSubscription `json:"data"`
@@ -0,0 +1,133 @@
+// Package events — внутрипроцессная шина событий об изменении подписок для потоковых API.
+package events
+
+import (
+	"sync"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+)
+
+// subscriberBuffer сколько событий может ждать отправки одному подписчику. Подписчика,
+// который не успевает их забирать, шина отключает: клиент переподключится с Last-Event-ID
+// и дочитает пропущенное из кольцевого буфера.
+const subscriberBuffer = 64
+
+// Event событие шины; Seq возрастает на единицу и служит id события в SSE
+type Event struct {
+	Seq uint64
+	dto.SubscriptionEvent
+}
+
+// Bus рассылает события подписчикам и хранит последние size событий для дочитывания
+// после переподключения. Нулевой *Bus допустим: публикация в него ничего не делает.
+type Bus struct {
+	mu          sync.Mutex
+	ring        []Event
+	next        int
+	seq         uint64
+	subscribers map[*Subscriber]struct{}
+	closed      bool
+}
+
+func NewBus(size int) *Bus {
+	return &Bus{
+		ring:        make([]Event, 0, size),
+		subscribers: make(map[*Subscriber]struct{}),
+	}
+}
+
+// Subscriber получает события шины через канал C. Канал закрывается при отписке
+// или если подписчик отстал больше чем на subscriberBuffer событий.
+type Subscriber struct {
+	C <-chan Event
+
+	ch     chan Event
+	filter func(Event) bool
+}
+
+// Publish присваивает событию номер, сохраняет его в буфере и рассылает подписчикам
+func (b *Bus) Publish(event dto.SubscriptionEvent) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e := Event{Seq: b.seq, SubscriptionEvent: event}
+	if len(b.ring) < cap(b.ring) {
+		b.ring = append(b.ring, e)
+	} else if cap(b.ring) > 0 {
+		b.ring[b.next] = e
+		b.next = (b.next + 1) % cap(b.ring)
+	}
+
+	for s := range b.subscribers {
+		if s.filter != nil && !s.filter(e) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			b.remove(s)
+		}
+	}
+}
+
+// Subscribe подписывает на события, прошедшие filter (nil — все). Вместе с подпиской
+// возвращаются события из буфера с номером больше lastSeq, так что между дочитанным
+// и новыми событиями нет ни пропусков, ни повторов. Если lastSeq уже вытеснен из буфера,
+// возвращается весь буфер.
+func (b *Bus) Subscribe(lastSeq uint64, filter func(Event) bool) (*Subscriber, []Event) {
+	ch := make(chan Event, subscriberBuffer)
+	s := &Subscriber{C: ch, ch: ch, filter: filter}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(ch)
+		return s, nil
+	}
+
+	var missed []Event
+	if lastSeq > 0 {
+		for i := range b.ring {
+			e := b.ring[(b.next+i)%len(b.ring)]
+			if e.Seq > lastSeq && (filter == nil || filter(e)) {
+				missed = append(missed, e)
+			}
+		}
+	}
+
+	b.subscribers[s] = struct{}{}
+	return s, missed
+}
+
+// Unsubscribe отписывает и закрывает канал подписчика; повторный вызов безопасен
+func (b *Bus) Unsubscribe(s *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(s)
+}
+
+// Close отключает всех подписчиков, чтобы потоковые ответы завершились и не держали
+// остановку HTTP-сервера; новые подписки после этого сразу получают закрытый канал
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for s := range b.subscribers {
+		b.remove(s)
+	}
+}
+
+func (b *Bus) remove(s *Subscriber) {
+	if _, ok := b.subscribers[s]; !ok {
+		return
+	}
+	delete(b.subscribers, s)
+	close(s.ch)
+}
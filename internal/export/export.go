@@ -0,0 +1,231 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/xuri/excelize/v2"
+)
+
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson"
+	XLSX   Format = "xlsx"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+var contentTypes = map[Format]string{
+	CSV:    "text/csv; charset=utf-8",
+	NDJSON: "application/x-ndjson",
+	XLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+var columns = []string{
+	"id", "service_name", "price", "user_id", "start_date", "end_date", "created_at", "updated_at", "deleted_at",
+}
+
+func (f Format) ContentType() string {
+	return contentTypes[f]
+}
+
+// Negotiate выбирает формат: параметр format важнее заголовка Accept,
+// без обоих используется CSV.
+func Negotiate(format, accept string) (Format, error) {
+	if format != "" {
+		switch f := Format(strings.ToLower(format)); f {
+		case CSV, NDJSON, XLSX:
+			return f, nil
+		case "jsonl":
+			return NDJSON, nil
+		}
+		return "", ErrUnsupportedFormat
+	}
+
+	if accept == "" {
+		return CSV, nil
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv", "*/*", "text/*":
+			return CSV, nil
+		case "application/x-ndjson", "application/jsonl", "application/json-lines":
+			return NDJSON, nil
+		case contentTypes[XLSX]:
+			return XLSX, nil
+		}
+	}
+
+	return "", ErrUnsupportedFormat
+}
+
+// Writer пишет подписки в выбранном формате. Ничего не пишется в w
+// до первого Write или Close, поэтому до этого момента ещё можно ответить ошибкой.
+// Abort освобождает ресурсы без записи документа.
+type Writer interface {
+	Write(sub dto.ResponseSubscription) error
+	Close() error
+	Abort()
+}
+
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case NDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case XLSX:
+		return newXLSXWriter(w)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) Write(sub dto.ResponseSubscription) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	return c.w.Write(record(sub))
+}
+
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Abort() {}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(sub dto.ResponseSubscription) error {
+	return n.enc.Encode(sub)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
+
+func (n *ndjsonWriter) Abort() {}
+
+// xlsxWriter использует потоковую запись excelize: строки сбрасываются
+// во временный файл, а в w документ пишется целиком при Close.
+type xlsxWriter struct {
+	w      io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	stream, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	header := make([]interface{}, len(columns))
+	for i, column := range columns {
+		header[i] = column
+	}
+	if err := stream.SetRow("A1", header); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &xlsxWriter{w: w, file: file, stream: stream, row: 1}, nil
+}
+
+func (x *xlsxWriter) Write(sub dto.ResponseSubscription) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+
+	values := record(sub)
+	row := make([]interface{}, len(values))
+	for i, value := range values {
+		row[i] = value
+	}
+	row[2] = sub.Price
+
+	return x.stream.SetRow(cell, row)
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.w)
+}
+
+func (x *xlsxWriter) Abort() {
+	x.file.Close()
+}
+
+// cell экранирует текст, который табличный редактор принял бы за формулу: значение,
+// начинающееся с =, +, -, @, табуляции или перевода строки, получает префикс '
+func cell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r\n", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func record(sub dto.ResponseSubscription) []string {
+	endDate := ""
+	if sub.EndDate != nil {
+		endDate = *sub.EndDate
+	}
+
+	deletedAt := ""
+	if sub.DeletedAt != nil {
+		deletedAt = sub.DeletedAt.Format(time.RFC3339)
+	}
+
+	return []string{
+		sub.ID.String(),
+		cell(sub.ServiceName),
+		strconv.Itoa(sub.Price),
+		sub.UserID.String(),
+		sub.StartDate,
+		endDate,
+		sub.CreatedAt.Format(time.RFC3339),
+		sub.UpdatedAt.Format(time.RFC3339),
+		deletedAt,
+	}
+}
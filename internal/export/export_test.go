@@ -0,0 +1,81 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/xuri/excelize/v2"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+)
+
+var formulaCases = []struct {
+	name, want string
+}{
+	{"Netflix", "Netflix"},
+	{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+	{"+1+1", "'+1+1"},
+	{"-2+3", "'-2+3"},
+	{"@SUM(A1)", "'@SUM(A1)"},
+	{"\t=1", "'\t=1"},
+	{"Yandex = Plus", "Yandex = Plus"},
+	{"", ""},
+}
+
+func TestCSVEscapesFormulas(t *testing.T) {
+	for _, tc := range formulaCases {
+		var buf bytes.Buffer
+		w, err := NewWriter(CSV, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(dto.ResponseSubscription{ID: uuid.New(), ServiceName: tc.name, Price: 100}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		records, err := csv.NewReader(&buf).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := records[1][1]; got != tc.want {
+			t.Errorf("service_name %q exported as %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestXLSXEscapesFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(XLSX, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range formulaCases {
+		if err := w.Write(dto.ResponseSubscription{ID: uuid.New(), ServiceName: tc.name, Price: 100}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for i, tc := range formulaCases {
+		cell, _ := excelize.CoordinatesToCellName(2, i+2)
+		if formula, _ := file.GetCellFormula("Sheet1", cell); formula != "" {
+			t.Errorf("service_name %q exported as formula %q", tc.name, formula)
+		}
+		if got, _ := file.GetCellValue("Sheet1", cell); got != tc.want {
+			t.Errorf("service_name %q exported as %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
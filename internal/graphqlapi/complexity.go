@@ -0,0 +1,217 @@
+package graphqlapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+const (
+	// maxQueryDepth наибольшая вложенность полей запроса
+	maxQueryDepth = 10
+	// maxQueryComplexity наибольшая оценка числа значений в ответе: поле внутри
+	// списка считается столько раз, сколько элементов может вернуть список
+	maxQueryComplexity = 5000
+)
+
+// checkComplexity оценивает запрос до выполнения. Ограничитель частоты считает POST
+// /graphql одним запросом, поэтому вложенные user { subscriptions } и страницы по
+// maxFirst элементов иначе разворачивались бы без предела. Синтаксические и прочие
+// ошибки документа оставлены graphql.Do.
+func checkComplexity(schema graphql.Schema, query string, variables map[string]interface{}) error {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return nil
+	}
+
+	c := &complexity{
+		schema:    schema,
+		fragments: make(map[string]*ast.FragmentDefinition),
+		visiting:  make(map[string]bool),
+	}
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok {
+			c.fragments[fragment.Name.Value] = fragment
+		}
+	}
+
+	for _, def := range doc.Definitions {
+		operation, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		var root *graphql.Object
+		switch operation.Operation {
+		case ast.OperationTypeQuery:
+			root = schema.QueryType()
+		case ast.OperationTypeMutation:
+			root = schema.MutationType()
+		}
+		if root == nil {
+			continue
+		}
+
+		c.variables = operationVariables(operation, variables)
+		if err := c.walk(operation.SelectionSet, root, 0, 1, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type complexity struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	visiting  map[string]bool
+	cost      int
+}
+
+// walk обходит выборку полей типа parent. multiplier — сколько раз выборка встречается
+// в ответе, pageSize — размер страницы, заданный аргументом first родительского поля
+func (c *complexity) walk(set *ast.SelectionSet, parent graphql.Type, depth, multiplier, pageSize int) error {
+	if set == nil {
+		return nil
+	}
+
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if err := c.field(selection, parent, depth, multiplier, pageSize); err != nil {
+				return err
+			}
+		case *ast.InlineFragment:
+			if err := c.walk(selection.SelectionSet, c.condition(selection.TypeCondition, parent), depth, multiplier, pageSize); err != nil {
+				return err
+			}
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment := c.fragments[name]
+			// циклы фрагментов отклонит проверка документа в graphql.Do
+			if fragment == nil || c.visiting[name] {
+				continue
+			}
+			c.visiting[name] = true
+			err := c.walk(fragment.SelectionSet, c.condition(fragment.TypeCondition, parent), depth, multiplier, pageSize)
+			c.visiting[name] = false
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *complexity) field(field *ast.Field, parent graphql.Type, depth, multiplier, pageSize int) error {
+	// служебные поля __schema, __type и __typename ограничены размером схемы
+	if strings.HasPrefix(field.Name.Value, "__") {
+		return nil
+	}
+
+	depth++
+	if depth > maxQueryDepth {
+		return fmt.Errorf("%w: depth exceeds %d", dto.ErrQueryTooComplex, maxQueryDepth)
+	}
+	c.cost += multiplier
+	if c.cost > maxQueryComplexity {
+		return fmt.Errorf("%w: complexity exceeds %d", dto.ErrQueryTooComplex, maxQueryComplexity)
+	}
+
+	object, ok := parent.(*graphql.Object)
+	if !ok {
+		return nil
+	}
+	def := object.Fields()[field.Name.Value]
+	if def == nil {
+		return nil
+	}
+
+	childPage := 0
+	for _, arg := range def.Args {
+		if arg.Name() == "first" {
+			childPage = c.first(field, arg)
+		}
+	}
+
+	fieldType := unwrapNonNull(def.Type)
+	if list, ok := fieldType.(*graphql.List); ok {
+		// список без first, например подписки пользователя, оценивается страницей maxFirst
+		factor := maxFirst
+		if pageSize > 0 {
+			factor = pageSize
+		}
+		multiplier = min(multiplier*factor, maxQueryComplexity+1)
+		fieldType = unwrapNonNull(list.OfType)
+	}
+
+	return c.walk(field.SelectionSet, fieldType, depth, multiplier, childPage)
+}
+
+// first размер страницы так же, как его ограничивает резолвер subscriptions
+func (c *complexity) first(field *ast.Field, def *graphql.Argument) int {
+	value := def.DefaultValue
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			value, _ = strconv.Atoi(v.Value)
+		case *ast.Variable:
+			if variable, ok := c.variables[v.Name.Value]; ok {
+				value = variable
+			}
+		}
+	}
+
+	var first int
+	switch v := value.(type) {
+	case int:
+		first = v
+	case float64:
+		first = int(v)
+	}
+	switch {
+	case first > maxFirst:
+		return maxFirst
+	case first <= 0:
+		return defaultFirst
+	}
+	return first
+}
+
+func (c *complexity) condition(named *ast.Named, parent graphql.Type) graphql.Type {
+	if named == nil {
+		return parent
+	}
+	if t := c.schema.Type(named.Name.Value); t != nil {
+		return t
+	}
+	return parent
+}
+
+// operationVariables значения переменных с учётом значений по умолчанию из операции
+func operationVariables(operation *ast.OperationDefinition, values map[string]interface{}) map[string]interface{} {
+	variables := make(map[string]interface{}, len(values))
+	for _, def := range operation.VariableDefinitions {
+		if v, ok := def.DefaultValue.(*ast.IntValue); ok {
+			variables[def.Variable.Name.Value], _ = strconv.Atoi(v.Value)
+		}
+	}
+	for name, value := range values {
+		variables[name] = value
+	}
+	return variables
+}
+
+func unwrapNonNull(t graphql.Type) graphql.Type {
+	if nonNull, ok := t.(*graphql.NonNull); ok {
+		return nonNull.OfType
+	}
+	return t
+}
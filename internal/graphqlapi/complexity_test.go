@@ -0,0 +1,93 @@
+package graphqlapi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+)
+
+func TestCheckComplexity(t *testing.T) {
+	schema, err := newSchema(&resolver{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		reject    bool
+	}{
+		{
+			name:  "page of subscriptions",
+			query: `{ subscriptions(first: 100) { totalCount edges { cursor node { id serviceName price user { id } } } } }`,
+		},
+		{
+			name:  "user with subscriptions",
+			query: `{ user(id: "1") { subscriptions { id price } totalCost(period: {start: "01-2025", end: "12-2025"}) { totalCost } } }`,
+		},
+		{
+			name:   "subscriptions of every user on a full page",
+			query:  `{ subscriptions(first: 100) { edges { node { user { subscriptions { id serviceName } } } } } }`,
+			reject: true,
+		},
+		{
+			name:  "small page with nested subscriptions",
+			query: `{ subscriptions(first: 2) { edges { node { user { subscriptions { id serviceName } } } } } }`,
+		},
+		{
+			name:      "page size from a variable",
+			query:     `query($n: Int) { subscriptions(first: $n) { edges { node { user { subscriptions { id } } } } } }`,
+			variables: map[string]interface{}{"n": float64(100)},
+			reject:    true,
+		},
+		{
+			name:   "page size from a variable default",
+			query:  `query($n: Int = 100) { subscriptions(first: $n) { edges { node { user { subscriptions { id } } } } } }`,
+			reject: true,
+		},
+		{
+			name:   "first above the maximum is capped, not trusted",
+			query:  `{ subscriptions(first: 100000) { edges { node { id } } } }`,
+			reject: false,
+		},
+		{
+			name: "deep nesting",
+			query: `{ subscription(id: "1") { user { subscriptions { user { subscriptions { user {
+				subscriptions { user { subscriptions { user { id } } } } } } } } } } }`,
+			reject: true,
+		},
+		{
+			name: "fields repeated through fragments",
+			query: `{ subscriptions(first: 100) { edges { node { ...S } } } }
+				fragment S on Subscription { user { subscriptions { ...F } } }
+				fragment F on Subscription { id price }`,
+			reject: true,
+		},
+		{
+			name:  "fragment cycle is left to validation",
+			query: `{ user(id: "1") { ...U } } fragment U on User { id ...U }`,
+		},
+		{
+			name:  "introspection",
+			query: `{ __schema { types { name fields { name type { name ofType { name ofType { name ofType { name ofType { name } } } } } } } } }`,
+		},
+		{
+			name:  "syntax error is left to graphql.Do",
+			query: `{ subscriptions(`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkComplexity(schema, tc.query, tc.variables)
+			if tc.reject && !errors.Is(err, dto.ErrQueryTooComplex) {
+				t.Fatalf("got %v, want ErrQueryTooComplex", err)
+			}
+			if !tc.reject && err != nil {
+				t.Fatalf("got %v, want query accepted", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package graphqlapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// resolverError ошибка резолвера со стабильным кодом в extensions.code,
+// как поле code в problem+json ответах REST API
+type resolverError struct {
+	err  error
+	code string
+}
+
+// newResolverError скрывает ошибки вне домена за internal_error, записывая их в лог
+func newResolverError(ctx context.Context, err error) error {
+	code := dto.Code(err)
+	if code == "" {
+		logger.FromContext(ctx).Error("GraphQL resolver failed", zap.Error(err))
+		return &resolverError{err: dto.ErrInternal, code: dto.ErrInternal.Code}
+	}
+	return &resolverError{err: err, code: code}
+}
+
+func (e *resolverError) Error() string {
+	return e.err.Error()
+}
+
+func (e *resolverError) Unwrap() error {
+	return e.err
+}
+
+func (e *resolverError) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.code}
+
+	var validationErr *dto.ValidationError
+	if errors.As(e.err, &validationErr) {
+		extensions["errors"] = validationErr.Fields
+	}
+	return extensions
+}
@@ -0,0 +1,66 @@
+package graphqlapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/BabichevDima/subManager/internal/config"
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/usecase"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/location"
+)
+
+// Request тело запроса к /graphql
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Handler обслуживает POST /graphql поверх SubscriptionUsecase
+type Handler struct {
+	schema  graphql.Schema
+	usecase *usecase.SubscriptionUsecase
+}
+
+func NewHandler(u *usecase.SubscriptionUsecase, cfg config.ConcurrencyConfig) (*Handler, error) {
+	schema, err := newSchema(&resolver{usecase: u, requireIfMatch: cfg.RequireIfMatch})
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{schema: schema, usecase: u}, nil
+}
+
+// ServeHTTP выполняет запрос. Ошибки GraphQL возвращаются в поле errors ответа 200
+// с кодом в extensions.code; 400 отдаётся, только если тело не разобрать.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Query == "" {
+		response.RespondWithError(w, r, dto.ErrInvalidBody)
+		return
+	}
+
+	if err := checkComplexity(h.schema, req.Query, req.Variables); err != nil {
+		response.RespondWithJSON(w, r, http.StatusOK, &graphql.Result{
+			Errors: []gqlerrors.FormattedError{{
+				Message:    err.Error(),
+				Locations:  []location.SourceLocation{},
+				Extensions: map[string]interface{}{"code": dto.ErrQueryTooComplex.Code},
+			}},
+		})
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        withLoaders(r.Context(), newLoaders(h.usecase)),
+	})
+
+	response.RespondWithJSON(w, r, http.StatusOK, result)
+}
@@ -0,0 +1,155 @@
+package graphqlapi
+
+import (
+	"context"
+	"sync"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/usecase"
+)
+
+// batchLoader копит ключи, запрошенные резолверами одного уровня запроса, и загружает их
+// одним вызовом fetch при первом обращении к результату. Исполнитель graphql-go сначала
+// вызывает резолверы всех элементов списка и только потом раскрывает возвращённые ими
+// функции, поэтому вложенные поля пользователей загружаются одним запросом, а не N.
+type batchLoader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending map[K]struct{}
+	results map[K]V
+	errs    map[K]error
+}
+
+func newBatchLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *batchLoader[K, V] {
+	return &batchLoader[K, V]{
+		fetch:   fetch,
+		pending: make(map[K]struct{}),
+		results: make(map[K]V),
+		errs:    make(map[K]error),
+	}
+}
+
+// load ставит ключ в очередь и возвращает отложенный результат; резолвер заворачивает
+// его в func() (interface{}, error), которую graphql-go раскроет после обхода уровня
+func (l *batchLoader[K, V]) load(ctx context.Context, key K) func() (V, error) {
+	l.mu.Lock()
+	if _, ok := l.results[key]; !ok {
+		l.pending[key] = struct{}{}
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if _, loaded := l.results[key]; !loaded && l.errs[key] == nil {
+			l.flush(ctx)
+		}
+		if err := l.errs[key]; err != nil {
+			var zero V
+			return zero, err
+		}
+		return l.results[key], nil
+	}
+}
+
+func (l *batchLoader[K, V]) flush(ctx context.Context) {
+	keys := make([]K, 0, len(l.pending))
+	for key := range l.pending {
+		keys = append(keys, key)
+	}
+	l.pending = make(map[K]struct{})
+
+	values, err := l.fetch(ctx, keys)
+	for _, key := range keys {
+		if err != nil {
+			l.errs[key] = err
+			continue
+		}
+		l.results[key] = values[key]
+	}
+}
+
+type userSubscriptionsKey struct {
+	userID         string
+	includeDeleted bool
+}
+
+type userTotalCostKey struct {
+	userID      string
+	serviceName string
+	startDate   string
+	endDate     string
+}
+
+// loaders загрузчики одного GraphQL-запроса; результаты не переживают запрос
+type loaders struct {
+	subscriptions *batchLoader[userSubscriptionsKey, []dto.ResponseSubscription]
+	totalCost     *batchLoader[userTotalCostKey, dto.TotalCostResponse]
+}
+
+func newLoaders(u *usecase.SubscriptionUsecase) *loaders {
+	return &loaders{
+		subscriptions: newBatchLoader(func(ctx context.Context, keys []userSubscriptionsKey) (map[userSubscriptionsKey][]dto.ResponseSubscription, error) {
+			userIDs := make(map[bool][]string)
+			for _, key := range keys {
+				userIDs[key.includeDeleted] = append(userIDs[key.includeDeleted], key.userID)
+			}
+
+			result := make(map[userSubscriptionsKey][]dto.ResponseSubscription, len(keys))
+			for includeDeleted, ids := range userIDs {
+				byUser, err := u.GetUsersSubscriptions(ctx, ids, includeDeleted)
+				if err != nil {
+					return nil, err
+				}
+				for _, id := range ids {
+					result[userSubscriptionsKey{userID: id, includeDeleted: includeDeleted}] = byUser[id]
+				}
+			}
+			return result, nil
+		}),
+		totalCost: newBatchLoader(func(ctx context.Context, keys []userTotalCostKey) (map[userTotalCostKey]dto.TotalCostResponse, error) {
+			requests := make(map[userTotalCostKey]*dto.UsersTotalCostRequest)
+			for _, key := range keys {
+				period := key
+				period.userID = ""
+				req, ok := requests[period]
+				if !ok {
+					req = &dto.UsersTotalCostRequest{
+						ServiceName: key.serviceName,
+						StartDate:   key.startDate,
+						EndDate:     key.endDate,
+					}
+					requests[period] = req
+				}
+				req.UserIDs = append(req.UserIDs, key.userID)
+			}
+
+			result := make(map[userTotalCostKey]dto.TotalCostResponse, len(keys))
+			for period, req := range requests {
+				byUser, err := u.CalculateUsersTotalCost(ctx, *req)
+				if err != nil {
+					return nil, err
+				}
+				for _, id := range req.UserIDs {
+					key := period
+					key.userID = id
+					result[key] = byUser[id]
+				}
+			}
+			return result, nil
+		}),
+	}
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	l, _ := ctx.Value(loadersKey{}).(*loaders)
+	return l
+}
@@ -0,0 +1,504 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"github.com/BabichevDima/subManager/internal/usecase"
+	"github.com/BabichevDima/subManager/internal/validation"
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	defaultFirst = 10
+	maxFirst     = 100
+	cursorPrefix = "subscription:"
+)
+
+type resolver struct {
+	usecase        *usecase.SubscriptionUsecase
+	requireIfMatch bool
+}
+
+// user узел пользователя; его подписки и стоимость загружаются пакетно через loaders
+type user struct {
+	id string
+}
+
+func newSchema(r *resolver) (graphql.Schema, error) {
+	periodInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "PeriodInput",
+		Description: "Период в формате MM-YYYY, включая оба месяца",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"start": {Type: graphql.NewNonNull(graphql.String)},
+			"end":   {Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+
+	totalCostType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TotalCost",
+		Fields: graphql.Fields{
+			"totalCost": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Float),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(dto.TotalCostResponse).TotalCost, nil
+				},
+			},
+			"subscriptionsCount": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Int),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(dto.TotalCostResponse).SubscriptionsCount, nil
+				},
+			},
+		},
+	})
+
+	var subscriptionType *graphql.Object
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.ID),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(user).id, nil
+					},
+				},
+				"subscriptions": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(subscriptionType))),
+					Args: graphql.FieldConfigArgument{
+						"includeDeleted": {Type: graphql.Boolean, DefaultValue: false},
+					},
+					Resolve: r.userSubscriptions,
+				},
+				"totalCost": &graphql.Field{
+					Type: graphql.NewNonNull(totalCostType),
+					Args: graphql.FieldConfigArgument{
+						"period":      {Type: graphql.NewNonNull(periodInput)},
+						"serviceName": {Type: graphql.String},
+					},
+					Resolve: r.userTotalCost,
+				},
+			}
+		}),
+	})
+
+	subscriptionType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"id":          subscriptionField(graphql.NewNonNull(graphql.ID), func(s dto.ResponseSubscription) interface{} { return s.ID.String() }),
+			"serviceName": subscriptionField(graphql.NewNonNull(graphql.String), func(s dto.ResponseSubscription) interface{} { return s.ServiceName }),
+			"price":       subscriptionField(graphql.NewNonNull(graphql.Int), func(s dto.ResponseSubscription) interface{} { return s.Price }),
+			"userId":      subscriptionField(graphql.NewNonNull(graphql.ID), func(s dto.ResponseSubscription) interface{} { return s.UserID.String() }),
+			"user":        subscriptionField(graphql.NewNonNull(userType), func(s dto.ResponseSubscription) interface{} { return user{id: s.UserID.String()} }),
+			"startDate":   subscriptionField(graphql.NewNonNull(graphql.String), func(s dto.ResponseSubscription) interface{} { return s.StartDate }),
+			"endDate": subscriptionField(graphql.String, func(s dto.ResponseSubscription) interface{} {
+				if s.EndDate == nil {
+					return nil
+				}
+				return *s.EndDate
+			}),
+			"version":   subscriptionField(graphql.NewNonNull(graphql.Int), func(s dto.ResponseSubscription) interface{} { return s.Version }),
+			"createdAt": subscriptionField(graphql.NewNonNull(graphql.DateTime), func(s dto.ResponseSubscription) interface{} { return s.CreatedAt }),
+			"updatedAt": subscriptionField(graphql.NewNonNull(graphql.DateTime), func(s dto.ResponseSubscription) interface{} { return s.UpdatedAt }),
+			"deletedAt": subscriptionField(graphql.DateTime, func(s dto.ResponseSubscription) interface{} {
+				if s.DeletedAt == nil {
+					return nil
+				}
+				return *s.DeletedAt
+			}),
+		},
+	})
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"endCursor":   &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	edgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SubscriptionEdge",
+		Fields: graphql.Fields{
+			"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"node":   &graphql.Field{Type: graphql.NewNonNull(subscriptionType)},
+		},
+	})
+
+	connectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "SubscriptionConnection",
+		Fields: graphql.Fields{
+			"edges":      &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(edgeType)))},
+			"pageInfo":   &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+			"totalCount": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+		},
+	})
+
+	filterInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "SubscriptionFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"userId":         {Type: graphql.ID},
+			"serviceName":    {Type: graphql.String},
+			"includeDeleted": {Type: graphql.Boolean, DefaultValue: false},
+		},
+	})
+
+	sortEnum := graphql.NewEnum(graphql.EnumConfig{
+		Name: "SubscriptionSort",
+		Values: graphql.EnumValueConfigMap{
+			"CREATED_AT_ASC":    {Value: dto.SubscriptionSort{Field: dto.SortCreatedAt}},
+			"CREATED_AT_DESC":   {Value: dto.SubscriptionSort{Field: dto.SortCreatedAt, Desc: true}},
+			"PRICE_ASC":         {Value: dto.SubscriptionSort{Field: dto.SortPrice}},
+			"PRICE_DESC":        {Value: dto.SubscriptionSort{Field: dto.SortPrice, Desc: true}},
+			"START_DATE_ASC":    {Value: dto.SubscriptionSort{Field: dto.SortStartDate}},
+			"START_DATE_DESC":   {Value: dto.SubscriptionSort{Field: dto.SortStartDate, Desc: true}},
+			"SERVICE_NAME_ASC":  {Value: dto.SubscriptionSort{Field: dto.SortServiceName}},
+			"SERVICE_NAME_DESC": {Value: dto.SubscriptionSort{Field: dto.SortServiceName, Desc: true}},
+		},
+	})
+
+	subscriptionInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "SubscriptionInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"serviceName": {Type: graphql.NewNonNull(graphql.String)},
+			"price":       {Type: graphql.NewNonNull(graphql.Int)},
+			"userId":      {Type: graphql.NewNonNull(graphql.ID)},
+			"startDate":   {Type: graphql.NewNonNull(graphql.String)},
+			"endDate":     {Type: graphql.String},
+		},
+	})
+
+	patchInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "SubscriptionPatch",
+		Description: "Частичное обновление: отсутствующее поле не меняется, clearEndDate снимает дату окончания",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"serviceName":  {Type: graphql.String},
+			"price":        {Type: graphql.Int},
+			"userId":       {Type: graphql.ID},
+			"startDate":    {Type: graphql.String},
+			"endDate":      {Type: graphql.String},
+			"clearEndDate": {Type: graphql.Boolean},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"subscription": &graphql.Field{
+				Type: subscriptionType,
+				Args: graphql.FieldConfigArgument{
+					"id":             {Type: graphql.NewNonNull(graphql.ID)},
+					"includeDeleted": {Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: r.subscription,
+			},
+			"subscriptions": &graphql.Field{
+				Type: graphql.NewNonNull(connectionType),
+				Args: graphql.FieldConfigArgument{
+					"filter": {Type: filterInput},
+					"sort":   {Type: sortEnum, DefaultValue: dto.SubscriptionSort{Field: dto.SortCreatedAt}},
+					"first":  {Type: graphql.Int, DefaultValue: defaultFirst},
+					"after":  {Type: graphql.String},
+				},
+				Resolve: r.subscriptions,
+			},
+			"user": &graphql.Field{
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.user,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createSubscription": &graphql.Field{
+				Type: graphql.NewNonNull(subscriptionType),
+				Args: graphql.FieldConfigArgument{
+					"input": {Type: graphql.NewNonNull(subscriptionInput)},
+				},
+				Resolve: r.createSubscription,
+			},
+			"updateSubscription": &graphql.Field{
+				Type: graphql.NewNonNull(subscriptionType),
+				Args: graphql.FieldConfigArgument{
+					"id":              {Type: graphql.NewNonNull(graphql.ID)},
+					"patch":           {Type: graphql.NewNonNull(patchInput)},
+					"expectedVersion": {Type: graphql.Int},
+				},
+				Resolve: r.updateSubscription,
+			},
+			"deleteSubscription": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id":              {Type: graphql.NewNonNull(graphql.ID)},
+					"expectedVersion": {Type: graphql.Int},
+				},
+				Resolve: r.deleteSubscription,
+			},
+			"restoreSubscription": &graphql.Field{
+				Type: graphql.NewNonNull(subscriptionType),
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: r.restoreSubscription,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+}
+
+func subscriptionField(t graphql.Output, value func(s dto.ResponseSubscription) interface{}) *graphql.Field {
+	return &graphql.Field{
+		Type: t,
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return value(p.Source.(dto.ResponseSubscription)), nil
+		},
+	}
+}
+
+func (r *resolver) subscription(p graphql.ResolveParams) (interface{}, error) {
+	includeDeleted, _ := p.Args["includeDeleted"].(bool)
+	if includeDeleted && !reqctx.IsAdmin(p.Context) {
+		return nil, newResolverError(p.Context, dto.ErrForbidden)
+	}
+
+	id, _ := p.Args["id"].(string)
+	subscription, err := r.usecase.GetSubscriptionByID(p.Context, id, includeDeleted)
+	if err != nil {
+		return nil, newResolverError(p.Context, err)
+	}
+	return subscription, nil
+}
+
+// subscriptions отдаёт страницу подписок в виде connection; курсор кодирует позицию
+// подписки в выборке с заданными фильтрами и порядком
+func (r *resolver) subscriptions(p graphql.ResolveParams) (interface{}, error) {
+	filter, _ := p.Args["filter"].(map[string]interface{})
+	req := dto.SubscriptionListRequest{}
+	req.UserID, _ = filter["userId"].(string)
+	req.ServiceName, _ = filter["serviceName"].(string)
+	req.IncludeDeleted, _ = filter["includeDeleted"].(bool)
+
+	if req.IncludeDeleted && !reqctx.IsAdmin(p.Context) {
+		return nil, newResolverError(p.Context, dto.ErrForbidden)
+	}
+	if err := validation.Struct(&req); err != nil {
+		return nil, newResolverError(p.Context, err)
+	}
+
+	first, _ := p.Args["first"].(int)
+	switch {
+	case first > maxFirst:
+		first = maxFirst
+	case first <= 0:
+		first = defaultFirst
+	}
+
+	offset := 0
+	if after, ok := p.Args["after"].(string); ok {
+		position, err := decodeCursor(after)
+		if err != nil {
+			return nil, newResolverError(p.Context, err)
+		}
+		offset = position + 1
+	}
+
+	sort, _ := p.Args["sort"].(dto.SubscriptionSort)
+	subscriptions, total, err := r.usecase.ListSubscriptions(p.Context, req, sort, offset, first)
+	if err != nil {
+		return nil, newResolverError(p.Context, err)
+	}
+
+	edges := make([]map[string]interface{}, len(subscriptions))
+	var endCursor interface{}
+	for i, subscription := range subscriptions {
+		cursor := encodeCursor(offset + i)
+		edges[i] = map[string]interface{}{"cursor": cursor, "node": subscription}
+		endCursor = cursor
+	}
+
+	return map[string]interface{}{
+		"edges":      edges,
+		"totalCount": total,
+		"pageInfo": map[string]interface{}{
+			"hasNextPage": int64(offset+len(subscriptions)) < total,
+			"endCursor":   endCursor,
+		},
+	}, nil
+}
+
+func (r *resolver) user(p graphql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(string)
+	req := dto.SubscriptionListRequest{UserID: id}
+	if err := validation.Struct(&req); err != nil {
+		return nil, newResolverError(p.Context, err)
+	}
+	return user{id: id}, nil
+}
+
+func (r *resolver) userSubscriptions(p graphql.ResolveParams) (interface{}, error) {
+	includeDeleted, _ := p.Args["includeDeleted"].(bool)
+	if includeDeleted && !reqctx.IsAdmin(p.Context) {
+		return nil, newResolverError(p.Context, dto.ErrForbidden)
+	}
+
+	key := userSubscriptionsKey{userID: p.Source.(user).id, includeDeleted: includeDeleted}
+	load := loadersFrom(p.Context).subscriptions.load(p.Context, key)
+	return func() (interface{}, error) {
+		subscriptions, err := load()
+		if err != nil {
+			return nil, newResolverError(p.Context, err)
+		}
+		if subscriptions == nil {
+			return []dto.ResponseSubscription{}, nil
+		}
+		return subscriptions, nil
+	}, nil
+}
+
+func (r *resolver) userTotalCost(p graphql.ResolveParams) (interface{}, error) {
+	period, _ := p.Args["period"].(map[string]interface{})
+	req := dto.TotalCostRequest{UserID: p.Source.(user).id}
+	req.ServiceName, _ = p.Args["serviceName"].(string)
+	req.StartDate, _ = period["start"].(string)
+	req.EndDate, _ = period["end"].(string)
+
+	if err := validation.Struct(&req); err != nil {
+		return nil, newResolverError(p.Context, err)
+	}
+
+	key := userTotalCostKey{userID: req.UserID, serviceName: req.ServiceName, startDate: req.StartDate, endDate: req.EndDate}
+	load := loadersFrom(p.Context).totalCost.load(p.Context, key)
+	return func() (interface{}, error) {
+		total, err := load()
+		if err != nil {
+			return nil, newResolverError(p.Context, err)
+		}
+		return total, nil
+	}, nil
+}
+
+func (r *resolver) createSubscription(p graphql.ResolveParams) (interface{}, error) {
+	input, _ := p.Args["input"].(map[string]interface{})
+	req := dto.RequestSubscription{}
+	req.ServiceName, _ = input["serviceName"].(string)
+	req.Price, _ = input["price"].(int)
+	req.UserID, _ = input["userId"].(string)
+	req.StartDate, _ = input["startDate"].(string)
+	req.EndDate, _ = input["endDate"].(string)
+
+	if err := validation.Struct(&req); err != nil {
+		return nil, newResolverError(p.Context, err)
+	}
+
+	subscription, err := r.usecase.Subscribe(p.Context, req)
+	if err != nil {
+		return nil, newResolverError(p.Context, err)
+	}
+	return subscription, nil
+}
+
+func (r *resolver) updateSubscription(p graphql.ResolveParams) (interface{}, error) {
+	input, _ := p.Args["patch"].(map[string]interface{})
+	patch := dto.UpdateSubscriptionRequest{
+		ServiceName: optionalString(input, "serviceName"),
+		UserID:      optionalString(input, "userId"),
+		StartDate:   optionalString(input, "startDate"),
+		EndDate:     optionalString(input, "endDate"),
+	}
+	if price, ok := input["price"].(int); ok {
+		patch.Price = dto.OptionalInt{Set: true, Value: price}
+	}
+
+	// graphql-go не передаёт явный null из запроса, поэтому дата окончания снимается флагом
+	if clear, _ := input["clearEndDate"].(bool); clear {
+		if patch.EndDate.Set {
+			return nil, newResolverError(p.Context, dto.InvalidField("clearEndDate", "cannot be combined with endDate"))
+		}
+		patch.EndDate = dto.OptionalString{Set: true, Null: true}
+	}
+
+	if err := validation.Struct(&patch); err != nil {
+		return nil, newResolverError(p.Context, err)
+	}
+
+	ifMatch, err := r.ifMatch(p.Context, p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := p.Args["id"].(string)
+	subscription, err := r.usecase.UpdateSubscription(p.Context, id, patch, ifMatch)
+	if err != nil {
+		return nil, newResolverError(p.Context, err)
+	}
+	return subscription, nil
+}
+
+func (r *resolver) deleteSubscription(p graphql.ResolveParams) (interface{}, error) {
+	ifMatch, err := r.ifMatch(p.Context, p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := p.Args["id"].(string)
+	if err := r.usecase.DeleteSubscription(p.Context, id, ifMatch); err != nil {
+		return nil, newResolverError(p.Context, err)
+	}
+	return true, nil
+}
+
+func (r *resolver) restoreSubscription(p graphql.ResolveParams) (interface{}, error) {
+	if !reqctx.IsAdmin(p.Context) {
+		return nil, newResolverError(p.Context, dto.ErrForbidden)
+	}
+
+	id, _ := p.Args["id"].(string)
+	subscription, err := r.usecase.RestoreSubscription(p.Context, id)
+	if err != nil {
+		return nil, newResolverError(p.Context, err)
+	}
+	return subscription, nil
+}
+
+// ifMatch переводит expectedVersion в условие на версию так же, как заголовок If-Match
+func (r *resolver) ifMatch(ctx context.Context, args map[string]interface{}) (dto.IfMatch, error) {
+	version, ok := args["expectedVersion"].(int)
+	if !ok {
+		if r.requireIfMatch {
+			return nil, newResolverError(ctx, dto.ErrPreconditionRequired)
+		}
+		return nil, nil
+	}
+	return dto.IfMatch{int64(version)}, nil
+}
+
+func optionalString(input map[string]interface{}, name string) dto.OptionalString {
+	value, ok := input[name].(string)
+	return dto.OptionalString{Set: ok, Value: value}
+}
+
+func encodeCursor(position int) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(position)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.StdEncoding.DecodeString(cursor)
+	if err == nil && strings.HasPrefix(string(raw), cursorPrefix) {
+		if position, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix)); err == nil && position >= 0 {
+			return position, nil
+		}
+	}
+	return 0, dto.InvalidField("after", "invalid cursor")
+}
@@ -0,0 +1,82 @@
+package grpcserver
+
+import (
+	"context"
+
+	"github.com/BabichevDima/subManager/internal/config"
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	apiKeyMetadata    = "x-api-key"
+	requestIDMetadata = "x-request-id"
+)
+
+// authenticator определяет автора вызова по ключу из метаданных x-api-key так же,
+// как HTTP-middleware Authenticate: без ключа вызов анонимный, неизвестный ключ отклоняется.
+type authenticator struct {
+	byKey map[string]config.APIKey
+}
+
+func newAuthenticator(keys []config.APIKey) *authenticator {
+	byKey := make(map[string]config.APIKey, len(keys))
+	for _, key := range keys {
+		byKey[key.Key] = key
+	}
+	return &authenticator{byKey: byKey}
+}
+
+func (a *authenticator) context(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	requestID := reqctx.NewRequestID(first(md.Get(requestIDMetadata)))
+	ctx = reqctx.WithRequestID(ctx, requestID)
+	ctx = logger.WithContext(ctx, logger.L.With(zap.String("request_id", requestID)))
+
+	if apiKey := first(md.Get(apiKeyMetadata)); apiKey != "" {
+		key, ok := a.byKey[apiKey]
+		if !ok {
+			return ctx, statusError(ctx, dto.ErrUnauthorized)
+		}
+		ctx = reqctx.WithActor(ctx, key.Actor, key.Admin)
+		ctx = logger.WithContext(ctx, logger.FromContext(ctx).With(zap.String("actor", key.Actor)))
+	}
+	return ctx, nil
+}
+
+func (a *authenticator) unary(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.context(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authenticator) stream(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.context(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// contextStream подменяет контекст потока контекстом с автором вызова
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,62 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// gRPC-коды доменных ошибок по их стабильному коду
+var codeByError = map[string]codes.Code{
+	dto.ErrSubscriptionExists.Code:   codes.AlreadyExists,
+	dto.ErrInvalidID.Code:            codes.InvalidArgument,
+	dto.ErrInvalidFormat.Code:        codes.InvalidArgument,
+	dto.ErrRecordNotFound.Code:       codes.NotFound,
+	dto.ErrNotDeleted.Code:           codes.FailedPrecondition,
+	dto.ErrPreconditionFailed.Code:   codes.Aborted,
+	dto.ErrPreconditionRequired.Code: codes.FailedPrecondition,
+	dto.ErrValidation.Code:           codes.InvalidArgument,
+	dto.ErrUnauthorized.Code:         codes.Unauthenticated,
+	dto.ErrForbidden.Code:            codes.PermissionDenied,
+}
+
+// statusError переводит ошибку usecase в статус gRPC. Ошибки полей передаются
+// в деталях google.rpc.BadRequest, неизвестные ошибки скрываются за Internal.
+func statusError(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	code, ok := codeByError[dto.Code(err)]
+	if !ok {
+		logger.FromContext(ctx).Error("gRPC request failed", zap.Error(err))
+		return status.Error(codes.Internal, dto.ErrInternal.Message)
+	}
+
+	st := status.New(code, err.Error())
+
+	var validationErr *dto.ValidationError
+	if errors.As(err, &validationErr) {
+		details := &errdetails.BadRequest{}
+		for _, field := range validationErr.Fields {
+			details.FieldViolations = append(details.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field.Field,
+				Description: field.Message,
+			})
+		}
+		if withDetails, err := st.WithDetails(details); err == nil {
+			st = withDetails
+		}
+	}
+
+	return st.Err()
+}
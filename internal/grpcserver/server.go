@@ -0,0 +1,74 @@
+// Package grpcserver — gRPC API подписок поверх того же SubscriptionUsecase, что и HTTP.
+package grpcserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/BabichevDima/subManager/internal/config"
+	"github.com/BabichevDima/subManager/internal/usecase"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	pb "github.com/BabichevDima/subManager/pkg/pb/submanager/v1"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+type Server struct {
+	server *grpc.Server
+	addr   string
+}
+
+func NewServer(u *usecase.SubscriptionUsecase, cfg config.GRPCConfig, keys []config.APIKey, concurrency config.ConcurrencyConfig) *Server {
+	auth := newAuthenticator(keys)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(auth.unary),
+		grpc.ChainStreamInterceptor(auth.stream),
+	)
+
+	pb.RegisterSubscriptionServiceServer(server, &subscriptionService{
+		usecase:        u,
+		requireIfMatch: concurrency.RequireIfMatch,
+	})
+	reflection.Register(server)
+
+	return &Server{server: server, addr: cfg.Addr}
+}
+
+// Start открывает порт и обслуживает вызовы в фоне
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", s.addr, err)
+	}
+
+	go func() {
+		logger.Info("gRPC server is listening", zap.String("address", s.addr))
+		if err := s.server.Serve(listener); err != nil {
+			logger.Error("gRPC server stopped", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// Shutdown дожидается завершения активных вызовов, а по истечении ctx обрывает их
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s == nil {
+		return nil
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,239 @@
+package grpcserver
+
+import (
+	"context"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"github.com/BabichevDima/subManager/internal/usecase"
+	"github.com/BabichevDima/subManager/internal/validation"
+	pb "github.com/BabichevDima/subManager/pkg/pb/submanager/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultPageSize = 10
+	maxPageSize     = 100
+)
+
+type subscriptionService struct {
+	pb.UnimplementedSubscriptionServiceServer
+
+	usecase        *usecase.SubscriptionUsecase
+	requireIfMatch bool
+}
+
+// CreateSubscription создаёт новую подписку
+func (s *subscriptionService) CreateSubscription(ctx context.Context, req *pb.CreateSubscriptionRequest) (*pb.Subscription, error) {
+	request := dto.RequestSubscription{
+		ServiceName: req.GetServiceName(),
+		Price:       int(req.GetPrice()),
+		UserID:      req.GetUserId(),
+		StartDate:   req.GetStartDate(),
+		EndDate:     req.GetEndDate(),
+	}
+	if err := validation.Struct(&request); err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	subscription, err := s.usecase.Subscribe(ctx, request)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return toProto(subscription), nil
+}
+
+// GetSubscription возвращает подписку по идентификатору
+func (s *subscriptionService) GetSubscription(ctx context.Context, req *pb.GetSubscriptionRequest) (*pb.Subscription, error) {
+	if err := checkIncludeDeleted(ctx, req.GetIncludeDeleted()); err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.usecase.GetSubscriptionByID(ctx, req.GetId(), req.GetIncludeDeleted())
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return toProto(subscription), nil
+}
+
+// UpdateSubscription частично обновляет подписку: меняются только переданные поля
+func (s *subscriptionService) UpdateSubscription(ctx context.Context, req *pb.UpdateSubscriptionRequest) (*pb.Subscription, error) {
+	var patch dto.UpdateSubscriptionRequest
+	if req.ServiceName != nil {
+		patch.ServiceName = dto.OptionalString{Set: true, Value: req.GetServiceName()}
+	}
+	if req.Price != nil {
+		patch.Price = dto.OptionalInt{Set: true, Value: int(req.GetPrice())}
+	}
+	if req.UserId != nil {
+		patch.UserID = dto.OptionalString{Set: true, Value: req.GetUserId()}
+	}
+	if req.StartDate != nil {
+		patch.StartDate = dto.OptionalString{Set: true, Value: req.GetStartDate()}
+	}
+	switch {
+	case req.EndDate != nil && req.GetClearEndDate():
+		return nil, statusError(ctx, dto.InvalidField("clear_end_date", "cannot be combined with end_date"))
+	case req.EndDate != nil:
+		patch.EndDate = dto.OptionalString{Set: true, Value: req.GetEndDate()}
+	case req.GetClearEndDate():
+		patch.EndDate = dto.OptionalString{Set: true, Null: true}
+	}
+
+	if err := validation.Struct(&patch); err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	ifMatch, err := s.ifMatch(ctx, req.GetExpectedVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.usecase.UpdateSubscription(ctx, req.GetId(), patch, ifMatch)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return toProto(subscription), nil
+}
+
+// DeleteSubscription удаляет подписку
+func (s *subscriptionService) DeleteSubscription(ctx context.Context, req *pb.DeleteSubscriptionRequest) (*pb.DeleteSubscriptionResponse, error) {
+	ifMatch, err := s.ifMatch(ctx, req.GetExpectedVersion())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.usecase.DeleteSubscription(ctx, req.GetId(), ifMatch); err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &pb.DeleteSubscriptionResponse{}, nil
+}
+
+// ListSubscriptions возвращает страницу подписок с фильтрами
+func (s *subscriptionService) ListSubscriptions(ctx context.Context, req *pb.ListSubscriptionsRequest) (*pb.ListSubscriptionsResponse, error) {
+	filter, err := listRequest(ctx, req.GetUserId(), req.GetServiceName(), req.GetIncludeDeleted())
+	if err != nil {
+		return nil, err
+	}
+
+	page := int(req.GetPage())
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize > maxPageSize:
+		pageSize = maxPageSize
+	case pageSize <= 0:
+		pageSize = defaultPageSize
+	}
+
+	subscriptions, total, err := s.usecase.GetAllSubscriptions(ctx, filter, page, pageSize)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	resp := &pb.ListSubscriptionsResponse{
+		Subscriptions: make([]*pb.Subscription, 0, len(subscriptions)),
+		Total:         total,
+		Page:          int32(page),
+		PageSize:      int32(pageSize),
+		TotalPages:    (total + int64(pageSize) - 1) / int64(pageSize),
+	}
+	for _, subscription := range subscriptions {
+		resp.Subscriptions = append(resp.Subscriptions, toProto(subscription))
+	}
+	return resp, nil
+}
+
+// StreamSubscriptions отправляет все подписки по фильтрам по одной, без пагинации
+func (s *subscriptionService) StreamSubscriptions(req *pb.StreamSubscriptionsRequest, stream pb.SubscriptionService_StreamSubscriptionsServer) error {
+	ctx := stream.Context()
+	filter, err := listRequest(ctx, req.GetUserId(), req.GetServiceName(), req.GetIncludeDeleted())
+	if err != nil {
+		return err
+	}
+
+	err = s.usecase.ExportSubscriptions(ctx, filter, func(subscription dto.ResponseSubscription) error {
+		return stream.Send(toProto(subscription))
+	})
+	if err != nil {
+		return statusError(ctx, err)
+	}
+	return nil
+}
+
+// CalculateTotalCost считает стоимость подписок пользователя за период
+func (s *subscriptionService) CalculateTotalCost(ctx context.Context, req *pb.CalculateTotalCostRequest) (*pb.CalculateTotalCostResponse, error) {
+	request := dto.TotalCostRequest{
+		UserID:      req.GetUserId(),
+		ServiceName: req.GetServiceName(),
+		StartDate:   req.GetStartDate(),
+		EndDate:     req.GetEndDate(),
+	}
+	if err := validation.Struct(&request); err != nil {
+		return nil, statusError(ctx, err)
+	}
+
+	total, err := s.usecase.CalculateTotalCost(ctx, request)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &pb.CalculateTotalCostResponse{
+		TotalCost:          total.TotalCost,
+		SubscriptionsCount: int32(total.SubscriptionsCount),
+	}, nil
+}
+
+// ifMatch переводит expected_version в условие на версию так же, как заголовок If-Match
+func (s *subscriptionService) ifMatch(ctx context.Context, version int64) (dto.IfMatch, error) {
+	if version == 0 {
+		if s.requireIfMatch {
+			return nil, statusError(ctx, dto.ErrPreconditionRequired)
+		}
+		return nil, nil
+	}
+	return dto.IfMatch{version}, nil
+}
+
+// checkIncludeDeleted разрешает видеть удалённые подписки только администраторам
+func checkIncludeDeleted(ctx context.Context, include bool) error {
+	if include && !reqctx.IsAdmin(ctx) {
+		return statusError(ctx, dto.ErrForbidden)
+	}
+	return nil
+}
+
+func listRequest(ctx context.Context, userID, serviceName string, withDeleted bool) (dto.SubscriptionListRequest, error) {
+	if err := checkIncludeDeleted(ctx, withDeleted); err != nil {
+		return dto.SubscriptionListRequest{}, err
+	}
+
+	req := dto.SubscriptionListRequest{
+		UserID:         userID,
+		ServiceName:    serviceName,
+		IncludeDeleted: withDeleted,
+	}
+	if err := validation.Struct(&req); err != nil {
+		return req, statusError(ctx, err)
+	}
+	return req, nil
+}
+
+func toProto(sub dto.ResponseSubscription) *pb.Subscription {
+	subscription := &pb.Subscription{
+		Id:          sub.ID.String(),
+		ServiceName: sub.ServiceName,
+		Price:       int32(sub.Price),
+		UserId:      sub.UserID.String(),
+		StartDate:   sub.StartDate,
+		EndDate:     sub.EndDate,
+		Version:     sub.Version,
+		CreatedAt:   timestamppb.New(sub.CreatedAt),
+		UpdatedAt:   timestamppb.New(sub.UpdatedAt),
+	}
+	if sub.DeletedAt != nil {
+		subscription.DeletedAt = timestamppb.New(*sub.DeletedAt)
+	}
+	return subscription
+}
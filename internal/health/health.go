@@ -0,0 +1,102 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+)
+
+// Check проверка одной части приложения; nil означает, что часть готова
+type Check func(ctx context.Context) error
+
+type component struct {
+	name  string
+	check Check
+}
+
+// Checker отвечает на пробы живости и готовности. Готовность проверяет все
+// добавленные части параллельно и падает, как только draining сообщает о начале
+// остановки, чтобы балансировщик успел увести трафик.
+type Checker struct {
+	timeout    time.Duration
+	draining   func() bool
+	components []component
+}
+
+func NewChecker(timeout time.Duration, draining func() bool) *Checker {
+	return &Checker{timeout: timeout, draining: draining}
+}
+
+// Add добавляет проверку готовности; вызывается до запуска сервера
+func (c *Checker) Add(name string, check Check) {
+	c.components = append(c.components, component{name: name, check: check})
+}
+
+// Worker проверка фонового обработчика, который должен работать всё время жизни процесса
+func Worker(worker interface{ Running() bool }) Check {
+	return func(context.Context) error {
+		if !worker.Running() {
+			return errors.New("worker is not running")
+		}
+		return nil
+	}
+}
+
+// Live отвечает 200, пока процесс способен обрабатывать запросы
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	response.RespondWithJSON(w, r, http.StatusOK, dto.HealthResponse{Status: dto.HealthStatusOK})
+}
+
+// Ready отвечает 200, если все части готовы, и 503 с разбором по частям иначе
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+	defer cancel()
+
+	resp := dto.HealthResponse{
+		Status:     dto.HealthStatusOK,
+		Components: make(map[string]dto.ComponentHealth, len(c.components)+1),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, comp := range c.components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := componentHealth(comp.check(ctx))
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Components[comp.name] = result
+		}()
+	}
+	wg.Wait()
+
+	if c.draining != nil && c.draining() {
+		resp.Components["shutdown"] = componentHealth(errors.New("server is shutting down"))
+	}
+
+	status := http.StatusOK
+	for _, result := range resp.Components {
+		if result.Status != dto.HealthStatusOK {
+			resp.Status = dto.HealthStatusFail
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	response.RespondWithJSON(w, r, status, resp)
+}
+
+func componentHealth(err error) dto.ComponentHealth {
+	if err != nil {
+		return dto.ComponentHealth{Status: dto.HealthStatusFail, Error: err.Error()}
+	}
+	return dto.ComponentHealth{Status: dto.HealthStatusOK}
+}
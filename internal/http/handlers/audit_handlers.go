@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/usecase"
+	"github.com/BabichevDima/subManager/internal/validation"
+)
+
+type AuditHandler struct {
+	usecase *usecase.AuditUsecase
+}
+
+func NewAuditHandler(u *usecase.AuditUsecase) *AuditHandler {
+	return &AuditHandler{usecase: u}
+}
+
+// GetSubscriptionHistory возвращает журнал изменений подписки, начиная с последних
+func (h *AuditHandler) GetSubscriptionHistory(w http.ResponseWriter, r *http.Request) {
+	page, pageSize := parsePagination(r)
+
+	entries, total, err := h.usecase.GetSubscriptionHistory(r.Context(), r.PathValue("subscriptionId"), page, pageSize)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	response.RespondWithJSON(w, r, http.StatusOK, dto.AuditListResponse{
+		Data:       entries,
+		Pagination: newPagination(total, page, pageSize),
+	})
+}
+
+// GetAuditLog возвращает журнал изменений всех подписок с фильтрами по автору и периоду
+func (h *AuditHandler) GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	page, pageSize := parsePagination(r)
+
+	q := r.URL.Query()
+	req := dto.AuditLogRequest{
+		Actor: q.Get("actor"),
+		From:  q.Get("from"),
+		To:    q.Get("to"),
+	}
+	if err := validation.Struct(&req); err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	entries, total, err := h.usecase.GetAuditLog(r.Context(), req, page, pageSize)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	response.RespondWithJSON(w, r, http.StatusOK, dto.AuditListResponse{
+		Data:       entries,
+		Pagination: newPagination(total, page, pageSize),
+	})
+}
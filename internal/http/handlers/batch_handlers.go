@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/validation"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	maxBatchSize     = 100
+	maxBatchBodySize = 1 << 20
+)
+
+// BatchSubscriptions выполняет пакет операций create/update/delete; при atomic=true
+// (по умолчанию) все операции идут в одной транзакции
+func (h *SubscriptionHandler) BatchSubscriptions(w http.ResponseWriter, r *http.Request) {
+	atomic := true
+	if raw := r.URL.Query().Get("atomic"); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			response.RespondWithError(w, r, dto.InvalidField("atomic", "must be a boolean"))
+			return
+		}
+		atomic = value
+	}
+
+	var operations []dto.BatchOperation
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodySize)).Decode(&operations); err != nil {
+		response.RespondWithError(w, r, dto.ErrInvalidBody)
+		return
+	}
+
+	if len(operations) == 0 {
+		response.RespondWithError(w, r, dto.InvalidField("body", "at least one operation must be provided"))
+		return
+	}
+	if len(operations) > maxBatchSize {
+		response.RespondWithError(w, r, dto.InvalidField("body", fmt.Sprintf("a batch may contain at most %d operations", maxBatchSize)))
+		return
+	}
+
+	items := make([]dto.BatchItem, len(operations))
+	for i, op := range operations {
+		items[i] = h.parseBatchOperation(op)
+	}
+
+	outcomes, err := h.usecase.ExecuteBatch(r.Context(), items, atomic)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	result := dto.BatchResponse{
+		Atomic:  atomic,
+		Results: make([]dto.BatchItemResult, len(outcomes)),
+	}
+	for i, outcome := range outcomes {
+		item := batchItemResult(r, i, items[i], outcome)
+		if item.Error == nil {
+			result.Succeeded++
+		} else {
+			result.Failed++
+		}
+		result.Results[i] = item
+	}
+
+	status := http.StatusOK
+	if result.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	response.RespondWithJSON(w, r, status, result)
+}
+
+func (h *SubscriptionHandler) parseBatchOperation(op dto.BatchOperation) dto.BatchItem {
+	item := dto.BatchItem{Op: op.Op, ID: op.ID}
+	if op.Version != nil {
+		item.IfMatch = dto.IfMatch{*op.Version}
+	}
+	if item.Invalid = validation.Struct(&op); item.Invalid != nil {
+		return item
+	}
+
+	switch op.Op {
+	case dto.BatchOpCreate:
+		if item.Invalid = decodeBatchData(op.Data, &item.Create); item.Invalid != nil {
+			return item
+		}
+		item.Invalid = validation.Struct(&item.Create)
+	case dto.BatchOpUpdate:
+		if op.ID == "" {
+			item.Invalid = dto.InvalidField("id", "is required")
+			return item
+		}
+		if h.requireIfMatch && op.Version == nil {
+			item.Invalid = dto.InvalidField("version", "is required")
+			return item
+		}
+		if item.Invalid = decodeBatchData(op.Data, &item.Update); item.Invalid != nil {
+			return item
+		}
+		item.Invalid = validation.Struct(&item.Update)
+	case dto.BatchOpDelete:
+		switch {
+		case op.ID == "":
+			item.Invalid = dto.InvalidField("id", "is required")
+		case h.requireIfMatch && op.Version == nil:
+			item.Invalid = dto.InvalidField("version", "is required")
+		}
+	}
+
+	return item
+}
+
+func decodeBatchData(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 {
+		return dto.InvalidField("data", "is required")
+	}
+	if err := json.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+		return dto.ErrInvalidBody
+	}
+	return nil
+}
+
+func batchItemResult(r *http.Request, index int, item dto.BatchItem, outcome dto.BatchOutcome) dto.BatchItemResult {
+	result := dto.BatchItemResult{Index: index, Op: item.Op}
+
+	if id, err := uuid.Parse(item.ID); err == nil {
+		result.ID = &id
+	}
+
+	if outcome.Err != nil {
+		problem := response.NewProblem(r, outcome.Err)
+		if problem.Status >= http.StatusInternalServerError {
+			logger.FromContext(r.Context()).Error("batch operation failed", zap.Int("index", index), zap.Error(outcome.Err))
+		}
+		result.Status = problem.Status
+		result.Error = &problem
+		return result
+	}
+
+	result.Data = outcome.Subscription
+	if outcome.Subscription != nil {
+		result.ID = &outcome.Subscription.ID
+	}
+
+	switch item.Op {
+	case dto.BatchOpCreate:
+		result.Status = http.StatusCreated
+	case dto.BatchOpDelete:
+		result.Status = http.StatusNoContent
+	default:
+		result.Status = http.StatusOK
+	}
+	return result
+}
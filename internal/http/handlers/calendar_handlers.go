@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/calendar"
+	"github.com/BabichevDima/subManager/internal/config"
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/usecase"
+	"github.com/google/uuid"
+)
+
+type CalendarHandler struct {
+	usecase  *usecase.SubscriptionUsecase
+	secret   string
+	reminder time.Duration
+}
+
+func NewCalendarHandler(u *usecase.SubscriptionUsecase, cfg config.CalendarConfig) *CalendarHandler {
+	return &CalendarHandler{
+		usecase:  u,
+		secret:   cfg.Secret,
+		reminder: cfg.Reminder,
+	}
+}
+
+// GetRenewalsCalendar отдаёт iCalendar с продлениями подписок пользователя по токену из ссылки
+func (h *CalendarHandler) GetRenewalsCalendar(w http.ResponseWriter, r *http.Request) {
+	if h.secret == "" {
+		response.RespondWithError(w, r, dto.ErrCalendarDisabled)
+		return
+	}
+
+	userID := r.PathValue("userId")
+	if !calendar.ValidToken(h.secret, userID, r.URL.Query().Get("token")) {
+		response.RespondWithError(w, r, dto.ErrInvalidToken)
+		return
+	}
+
+	subscriptions, err := h.usecase.GetUserRenewals(r.Context(), userID)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	feed := calendar.Feed{
+		Name:          "Subscription renewals",
+		Subscriptions: subscriptions,
+		Reminder:      h.reminder,
+		Now:           time.Now(),
+	}
+
+	var body bytes.Buffer
+	if err := feed.Write(&body); err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", calendar.ContentType)
+	w.Header().Set("Content-Disposition", `inline; filename="renewals.ics"`)
+	w.WriteHeader(http.StatusOK)
+	body.WriteTo(w)
+}
+
+// GetRenewalsCalendarLink выдаёт ссылку с токеном на календарь продлений пользователя
+func (h *CalendarHandler) GetRenewalsCalendarLink(w http.ResponseWriter, r *http.Request) {
+	if h.secret == "" {
+		response.RespondWithError(w, r, dto.ErrCalendarDisabled)
+		return
+	}
+
+	userID := r.PathValue("userId")
+	if _, err := uuid.Parse(userID); err != nil {
+		response.RespondWithError(w, r, dto.ErrInvalidID)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	link := url.URL{
+		Scheme:   scheme,
+		Host:     r.Host,
+		Path:     "/api/users/" + userID + "/renewals.ics",
+		RawQuery: url.Values{"token": {calendar.Token(h.secret, userID)}}.Encode(),
+	}
+
+	response.RespondWithJSON(w, r, http.StatusOK, dto.CalendarLinkResponse{URL: link.String()})
+}
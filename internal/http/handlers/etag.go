@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+)
+
+// etag возвращает сильный ETag для версии подписки
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// notModified проверяет If-None-Match; по RFC 9110 здесь используется слабое сравнение
+func notModified(r *http.Request, version int64) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+// ifMatch разбирает заголовок If-Match в ожидаемые версии подписки. Если заголовок
+// обязателен, но не передан, отвечает 428 и возвращает false.
+func (h *SubscriptionHandler) ifMatch(w http.ResponseWriter, r *http.Request) (dto.IfMatch, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if h.requireIfMatch {
+			response.RespondWithError(w, r, dto.ErrPreconditionRequired)
+			return nil, false
+		}
+		return nil, true
+	}
+
+	versions := dto.IfMatch{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true
+		}
+
+		// If-Match использует сильное сравнение, поэтому слабые ETag не совпадают ни с чем
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		if version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64); err == nil {
+			versions = append(versions, version)
+		}
+	}
+	return versions, true
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/config"
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/events"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/validation"
+	"github.com/google/uuid"
+)
+
+const EventStreamContentType = "text/event-stream"
+
+type EventsHandler struct {
+	bus       *events.Bus
+	keepAlive time.Duration
+}
+
+func NewEventsHandler(bus *events.Bus, cfg config.EventsConfig) *EventsHandler {
+	return &EventsHandler{bus: bus, keepAlive: cfg.KeepAlive}
+}
+
+// StreamEvents отправляет изменения подписок потоком Server-Sent Events. С заголовком
+// Last-Event-ID сначала дочитываются пропущенные события из буфера шины.
+func (h *EventsHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	req := dto.EventStreamRequest{UserID: r.URL.Query().Get("user_id")}
+	if err := validation.Struct(&req); err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	var filter func(events.Event) bool
+	if req.UserID != "" {
+		userID := uuid.MustParse(req.UserID)
+		filter = func(e events.Event) bool {
+			return e.Subscription.UserID == userID
+		}
+	}
+
+	// Last-Event-ID выставляет сам браузер при переподключении; чужое значение просто игнорируется
+	lastSeq, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+
+	subscriber, missed := h.bus.Subscribe(lastSeq, filter)
+	defer h.bus.Unsubscribe(subscriber)
+
+	// поток живёт дольше WriteTimeout сервера
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", EventStreamContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range missed {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(h.keepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-subscriber.C:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event events.Event) error {
+	data, err := json.Marshal(event.SubscriptionEvent)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Seq, event.Type, data)
+	return err
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/export"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ExportSubscriptions потоково выгружает подписки с фильтрами списка в CSV, NDJSON или XLSX
+func (h *SubscriptionHandler) ExportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	req, ok := listRequest(w, r)
+	if !ok {
+		return
+	}
+
+	h.streamExport(w, r, "subscriptions", func(write func(dto.ResponseSubscription) error) error {
+		return h.usecase.ExportSubscriptions(r.Context(), req, write)
+	})
+}
+
+// ExportTotalCost потоково выгружает подписки, из которых складывается стоимость за период
+func (h *SubscriptionHandler) ExportTotalCost(w http.ResponseWriter, r *http.Request) {
+	req, ok := totalCostRequest(w, r)
+	if !ok {
+		return
+	}
+
+	h.streamExport(w, r, "subscriptions-cost", func(write func(dto.ResponseSubscription) error) error {
+		return h.usecase.ExportTotalCost(r.Context(), req, write)
+	})
+}
+
+// streamExport пишет выгрузку в ответ по мере чтения строк из БД.
+// Пока в ответ ничего не записано, ошибки возвращаются как application/problem+json;
+// после этого соединение обрывается, чтобы клиент не принял неполный файл за целый.
+func (h *SubscriptionHandler) streamExport(w http.ResponseWriter, r *http.Request, name string, run func(write func(dto.ResponseSubscription) error) error) {
+	format, err := export.Negotiate(r.URL.Query().Get("format"), r.Header.Get("Accept"))
+	if err != nil {
+		response.RespondWithError(w, r, fmt.Errorf("%w: supported formats are csv, ndjson, xlsx", dto.ErrNotAcceptable))
+		return
+	}
+
+	body := &countingWriter{w: w, rc: http.NewResponseController(w), timeout: h.writeTimeout}
+	writer, err := export.NewWriter(format, body)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+"."+string(format)+`"`)
+
+	err = run(writer.Write)
+	if err == nil {
+		err = writer.Close()
+	} else {
+		writer.Abort()
+	}
+	if err == nil {
+		return
+	}
+
+	if body.n > 0 {
+		logger.FromContext(r.Context()).Error("export aborted", zap.String("format", string(format)), zap.Error(err))
+		panic(http.ErrAbortHandler)
+	}
+
+	w.Header().Del("Content-Disposition")
+	response.RespondWithError(w, r, err)
+}
+
+// countingWriter считает записанные байты и переносит срок записи на каждый фрагмент:
+// выгрузка большой выборки идёт дольше WriteTimeout сервера, а клиент, который
+// перестал читать ответ, всё равно отключается и освобождает курсор в БД
+type countingWriter struct {
+	w       io.Writer
+	n       int64
+	rc      *http.ResponseController
+	timeout time.Duration
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.timeout > 0 {
+		_ = c.rc.SetWriteDeadline(time.Now().Add(c.timeout))
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/validation"
+)
+
+const (
+	maxImportSize = 10 << 20
+	maxImportRows = 10000
+)
+
+var importColumns = []string{"service_name", "price", "user_id", "start_date", "end_date"}
+
+type importOptions struct {
+	delimiter  rune
+	dateLayout string
+	dryRun     bool
+}
+
+// ImportSubscriptions создаёт подписки из CSV-файла в одной транзакции; некорректные строки
+// и дубликаты пропускаются и попадают в отчёт
+func (h *SubscriptionHandler) ImportSubscriptions(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseImportOptions(r.URL.Query())
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+
+	source, err := importSource(r)
+	if err != nil {
+		respondImportError(w, r, err)
+		return
+	}
+	defer source.Close()
+
+	rows, err := readImportCSV(source, opts)
+	if err != nil {
+		respondImportError(w, r, err)
+		return
+	}
+
+	report, err := h.usecase.ImportSubscriptions(r.Context(), rows, opts.dryRun)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	response.RespondWithJSON(w, r, http.StatusOK, report)
+}
+
+// respondImportError отвечает на ошибку чтения файла: превышение размера даёт 413,
+// ошибки разбора CSV — 400 invalid_body с описанием
+func respondImportError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		response.RespondWithError(w, r, dto.ErrBodyTooLarge)
+	case errors.Is(err, dto.ErrValidation):
+		response.RespondWithError(w, r, err)
+	default:
+		response.RespondWithError(w, r, fmt.Errorf("%w: %v", dto.ErrInvalidBody, err))
+	}
+}
+
+func parseImportOptions(q url.Values) (importOptions, error) {
+	opts := importOptions{delimiter: ',', dateLayout: "01-2006"}
+
+	switch delimiter := q.Get("delimiter"); {
+	case delimiter == "":
+	case delimiter == "tab" || delimiter == `\t`:
+		opts.delimiter = '\t'
+	case utf8.RuneCountInString(delimiter) == 1:
+		opts.delimiter, _ = utf8.DecodeRuneInString(delimiter)
+		if opts.delimiter == '"' || opts.delimiter == '\r' || opts.delimiter == '\n' {
+			return opts, dto.InvalidField("delimiter", fmt.Sprintf("%q is not allowed", delimiter))
+		}
+	default:
+		return opts, dto.InvalidField("delimiter", "must be a single character")
+	}
+
+	if format := q.Get("date_format"); format != "" {
+		layout, err := dateLayout(format)
+		if err != nil {
+			return opts, err
+		}
+		opts.dateLayout = layout
+	}
+
+	if dryRun := q.Get("dry_run"); dryRun != "" {
+		value, err := strconv.ParseBool(dryRun)
+		if err != nil {
+			return opts, dto.InvalidField("dry_run", "must be a boolean")
+		}
+		opts.dryRun = value
+	}
+
+	return opts, nil
+}
+
+// dateLayout переводит формат вида MM-YYYY в layout для time.Parse
+func dateLayout(format string) (string, error) {
+	layout := strings.NewReplacer("YYYY", "2006", "YY", "06", "MM", "01", "DD", "02").Replace(format)
+	if !strings.Contains(layout, "01") || !strings.Contains(layout, "06") {
+		return "", dto.InvalidField("date_format", "must contain MM and YYYY or YY")
+	}
+	return layout, nil
+}
+
+func importSource(r *http.Request) (io.ReadCloser, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, err
+		}
+		return nil, dto.InvalidField("file", "is required")
+	}
+	return file, nil
+}
+
+func readImportCSV(source io.Reader, opts importOptions) ([]dto.ImportRow, error) {
+	reader := csv.NewReader(source)
+	reader.Comma = opts.delimiter
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("CSV file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	columns, err := importHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []dto.ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("CSV file has more than %d rows", maxImportRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		rows = append(rows, importRow(line, record, len(header), columns, opts))
+	}
+
+	return rows, nil
+}
+
+func importHeader(header []string) (map[string]int, error) {
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !isImportColumn(name) {
+			return nil, fmt.Errorf("unknown column %q", name)
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+
+	for _, name := range importColumns {
+		if _, ok := columns[name]; !ok && name != "end_date" {
+			return nil, fmt.Errorf("required column %q is missing", name)
+		}
+	}
+
+	return columns, nil
+}
+
+func isImportColumn(name string) bool {
+	for _, column := range importColumns {
+		if column == name {
+			return true
+		}
+	}
+	return false
+}
+
+func importRow(line int, record []string, width int, columns map[string]int, opts importOptions) dto.ImportRow {
+	row := dto.ImportRow{Line: line}
+	if len(record) != width {
+		row.Error = fmt.Sprintf("expected %d fields, got %d", width, len(record))
+		return row
+	}
+
+	value := func(name string) string {
+		i, ok := columns[name]
+		if !ok {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	row.Request.ServiceName = value("service_name")
+	row.Request.UserID = value("user_id")
+
+	if price := value("price"); price != "" {
+		parsed, err := strconv.Atoi(price)
+		if err != nil {
+			row.Error = "price must be an integer"
+			return row
+		}
+		row.Request.Price = parsed
+	}
+
+	var err error
+	if row.Request.StartDate, err = importDate(value("start_date"), opts.dateLayout); err != nil {
+		row.Error = "start_date: " + err.Error()
+		return row
+	}
+	if row.Request.EndDate, err = importDate(value("end_date"), opts.dateLayout); err != nil {
+		row.Error = "end_date: " + err.Error()
+		return row
+	}
+
+	if err := validation.Struct(&row.Request); err != nil {
+		row.Error = err.Error()
+	}
+	return row
+}
+
+// importDate приводит дату из CSV к формату MM-YYYY, который ожидает usecase
+func importDate(value, layout string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	date, err := time.Parse(layout, value)
+	if err != nil {
+		return "", dto.ErrInvalidFormat
+	}
+	return date.Format("01-2006"), nil
+}
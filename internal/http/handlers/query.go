@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"github.com/BabichevDima/subManager/internal/validation"
+)
+
+func parsePagination(r *http.Request) (int, int) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	switch {
+	case pageSize > 100:
+		pageSize = 100
+	case pageSize <= 0:
+		pageSize = 10
+	}
+
+	return page, pageSize
+}
+
+func newPagination(total int64, page, pageSize int) dto.PaginationResponse {
+	return dto.PaginationResponse{
+		Total:      total,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: (total + int64(pageSize) - 1) / int64(pageSize),
+	}
+}
+
+// includeDeleted разбирает флаг include_deleted; удалённые подписки видны только администраторам
+func includeDeleted(w http.ResponseWriter, r *http.Request) (bool, bool) {
+	raw := r.URL.Query().Get("include_deleted")
+	if raw == "" {
+		return false, true
+	}
+
+	include, err := strconv.ParseBool(raw)
+	if err != nil {
+		response.RespondWithError(w, r, dto.InvalidField("include_deleted", "must be a boolean"))
+		return false, false
+	}
+
+	if include && !reqctx.IsAdmin(r.Context()) {
+		response.RespondWithError(w, r, dto.ErrForbidden)
+		return false, false
+	}
+
+	return include, true
+}
+
+func listRequest(w http.ResponseWriter, r *http.Request) (dto.SubscriptionListRequest, bool) {
+	withDeleted, ok := includeDeleted(w, r)
+	if !ok {
+		return dto.SubscriptionListRequest{}, false
+	}
+
+	q := r.URL.Query()
+	req := dto.SubscriptionListRequest{
+		UserID:         q.Get("user_id"),
+		ServiceName:    q.Get("service_name"),
+		IncludeDeleted: withDeleted,
+	}
+	if err := validation.Struct(&req); err != nil {
+		response.RespondWithError(w, r, err)
+		return dto.SubscriptionListRequest{}, false
+	}
+
+	return req, true
+}
+
+func totalCostRequest(w http.ResponseWriter, r *http.Request) (dto.TotalCostRequest, bool) {
+	q := r.URL.Query()
+	req := dto.TotalCostRequest{
+		UserID:      q.Get("user_id"),
+		ServiceName: q.Get("service_name"),
+		StartDate:   q.Get("start_date"),
+		EndDate:     q.Get("end_date"),
+	}
+
+	if err := validation.Struct(&req); err != nil {
+		response.RespondWithError(w, r, err)
+		return dto.TotalCostRequest{}, false
+	}
+
+	return req, true
+}
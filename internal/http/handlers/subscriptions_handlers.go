@@ -2,281 +2,220 @@ package handlers
 
 import (
 	"encoding/json"
-	"errors"
+	"fmt"
+	"mime"
 	"net/http"
-	"strconv"
-	"strings"
+	"time"
 
+	"github.com/BabichevDima/subManager/internal/config"
 	"github.com/BabichevDima/subManager/internal/dto"
 	"github.com/BabichevDima/subManager/internal/http/response"
 	"github.com/BabichevDima/subManager/internal/usecase"
+	"github.com/BabichevDima/subManager/internal/validation"
 )
 
+const MergePatchContentType = "application/merge-patch+json"
+
 type SubscriptionHandler struct {
-	usecase *usecase.SubscriptionUsecase
+	usecase        *usecase.SubscriptionUsecase
+	requireIfMatch bool
+	// writeTimeout срок записи одного фрагмента выгрузки; 0 — срок сервера не меняется
+	writeTimeout time.Duration
 }
 
-func NewSubscriptionHandler(u *usecase.SubscriptionUsecase) *SubscriptionHandler {
-	return &SubscriptionHandler{usecase: u}
+func NewSubscriptionHandler(u *usecase.SubscriptionUsecase, cfg config.ConcurrencyConfig, writeTimeout time.Duration) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		usecase:        u,
+		requireIfMatch: cfg.RequireIfMatch,
+		writeTimeout:   writeTimeout,
+	}
 }
 
-// @title Subscriptions Management API
-// @version 1.0
-// @description API для управления подписками пользователей
-
-// @contact.name API Support
-// @contact.email support@submanager.com
-
-// @license.name MIT
-// @license.url https://opensource.org/licenses/MIT
-
-// @host localhost:8080
-// @BasePath /api
-// @schemes http
-
-// @securityDefinitions.apikey BearerAuth
-// @in header
-// @name Authorization
-
-// Subscribe godoc
-// @Summary Создать новую подписку
-// @Description Создает новую подписку для пользователя
-// @Tags Subscriptions
-// @Accept json
-// @Produce json
-// @Param input body dto.RequestSubscription true "Данные подписки"
-// @Success 201 {object} dto.ResponseSubscription
-// @Failure 400 {object} response.BadRequestError
-// @Failure 409 {object} response.ErrSubscriptionExists
-// @Failure 500 {object} response.InternalServerError
-// @Router /subscriptions [post]
+// Subscribe создаёт новую подписку
 func (h *SubscriptionHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	request := dto.RequestSubscription{}
 	err := decoder.Decode(&request)
 	if err != nil {
-		response.RespondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		response.RespondWithError(w, r, dto.ErrInvalidBody)
 		return
 	}
 
-	if request.ServiceName == "" {
-		response.RespondWithError(w, http.StatusBadRequest, "ServiceName is required", err)
-		return
-	}
-	if request.Price <= 0 {
-		response.RespondWithError(w, http.StatusBadRequest, "Price must be positive", err)
-		return
-	}
-	if request.UserID == "" {
-		response.RespondWithError(w, http.StatusBadRequest, "UserID is required", err)
-		return
-	}
-	if request.StartDate == "" {
-		response.RespondWithError(w, http.StatusBadRequest, "StartDate is required", err)
+	if err := validation.Struct(&request); err != nil {
+		response.RespondWithError(w, r, err)
 		return
 	}
 
-	subscriptionResponse, err := h.usecase.Subscribe(request)
+	subscriptionResponse, err := h.usecase.Subscribe(r.Context(), request)
 	if err != nil {
-		if errors.Is(err, dto.ErrSubscriptionExists) {
-			response.RespondWithError(w, http.StatusConflict, "Subscription already exists", err)
-			return
-		}
-		response.RespondWithError(w, http.StatusInternalServerError, "Internal server error", err)
+		response.RespondWithError(w, r, err)
 		return
 	}
 
-	response.RespondWithJSON(w, http.StatusCreated, subscriptionResponse)
+	setETag(w, subscriptionResponse.Version)
+	response.RespondWithJSON(w, r, http.StatusCreated, subscriptionResponse)
 }
 
-// GetSubscriptionByID godoc
-// @Summary Получить подписку по ID
-// @Description Возвращает детали подписки по её идентификатору
-// @Tags Subscriptions
-// @Produce json
-// @Param subscriptionId path string true "ID подписки"
-// @Success 200 {object} dto.ResponseSubscription
-// @Failure 404 {object} response.BadRequestError
-// @Failure 500 {object} response.InternalServerError
-// @Router /subscriptions/{subscriptionId} [get]
+// GetSubscriptionByID возвращает подписку по идентификатору; с совпавшим If-None-Match — 304
 func (h *SubscriptionHandler) GetSubscriptionByID(w http.ResponseWriter, r *http.Request) {
 	subscriptionIdStr := r.PathValue("subscriptionId")
 
-	responseData, err := h.usecase.GetSubscriptionByID(subscriptionIdStr)
+	withDeleted, ok := includeDeleted(w, r)
+	if !ok {
+		return
+	}
+
+	responseData, err := h.usecase.GetSubscriptionByID(r.Context(), subscriptionIdStr, withDeleted)
 
 	if err != nil {
-		if errors.Is(err, dto.ErrSubscriptionNotFound) {
-			response.RespondWithError(w, http.StatusNotFound, "Subscription not found", err)
-			return
-		}
-		response.RespondWithError(w, http.StatusInternalServerError, "Internal server error", err)
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	setETag(w, responseData.Version)
+	if notModified(r, responseData.Version) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	response.RespondWithJSON(w, http.StatusOK, responseData)
+	response.RespondWithJSON(w, r, http.StatusOK, responseData)
 }
 
-// GetAllSubscriptions godoc
-// @Summary Получить список подписок
-// @Description Возвращает список подписок с пагинацией
-// @Tags Subscriptions
-// @Produce json
-// @Param page query int false "Номер страницы" default(1)
-// @Param pageSize query int false "Размер страницы" default(10)
-// @Success 200 {object} dto.SubscriptionListResponse
-// @Failure 500 {object} response.InternalServerError
-// @Router /subscriptions [get]
+// GetAllSubscriptions возвращает список подписок с пагинацией и фильтрами
 func (h *SubscriptionHandler) GetAllSubscriptions(w http.ResponseWriter, r *http.Request) {
-	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	if page <= 0 {
-		page = 1
-	}
+	page, pageSize := parsePagination(r)
 
-	pageSize, _ := strconv.Atoi(r.URL.Query().Get("pageSize"))
-	switch {
-	case pageSize > 100:
-		pageSize = 100
-	case pageSize <= 0:
-		pageSize = 10
+	req, ok := listRequest(w, r)
+	if !ok {
+		return
 	}
 
-	subscriptions, total, err := h.usecase.GetAllSubscriptions(page, pageSize)
+	subscriptions, total, err := h.usecase.GetAllSubscriptions(r.Context(), req, page, pageSize)
 	if err != nil {
-		response.RespondWithError(w, http.StatusInternalServerError, "Failed to get subscriptions", err)
+		response.RespondWithError(w, r, err)
 		return
 	}
 
 	responseData := dto.SubscriptionListResponse{
-		Data: subscriptions,
-		Pagination: dto.PaginationResponse{
-			Total:      total,
-			Page:       page,
-			PageSize:   pageSize,
-			TotalPages: (total + int64(pageSize) - 1) / int64(pageSize),
-		},
+		Data:       subscriptions,
+		Pagination: newPagination(total, page, pageSize),
 	}
 
-	response.RespondWithJSON(w, http.StatusOK, responseData)
+	response.RespondWithJSON(w, r, http.StatusOK, responseData)
 }
 
-// DeleteSubscription godoc
-// @Summary Удалить подписку
-// @Description Удаляет подписку по её идентификатору
-// @Tags Subscriptions
-// @Param subscriptionId path string true "ID подписки"
-// @Success 204
-// @Failure 400 {object} response.BadRequestError
-// @Failure 404 {object} response.BadRequestError
-// @Failure 500 {object} response.InternalServerError
-// @Router /subscriptions/{subscriptionId} [delete]
+// DeleteSubscription удаляет подписку
 func (h *SubscriptionHandler) DeleteSubscription(w http.ResponseWriter, r *http.Request) {
 	subscriptionId := r.PathValue("subscriptionId")
 
 	if subscriptionId == "" {
-		response.RespondWithError(w, http.StatusBadRequest, "Subscription ID is required", nil)
+		response.RespondWithError(w, r, dto.InvalidField("subscriptionId", "is required"))
 		return
 	}
 
-	err := h.usecase.DeleteSubscription(subscriptionId)
+	ifMatch, ok := h.ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.usecase.DeleteSubscription(r.Context(), subscriptionId, ifMatch)
 	if err != nil {
-		switch err {
-		case dto.ErrRecordNotFound:
-			response.RespondWithError(w, http.StatusNotFound, "Subscription not found", err)
-		case dto.ErrInvalidID:
-			response.RespondWithError(w, http.StatusBadRequest, "Invalid subscription ID format", err)
-		default:
-			response.RespondWithError(w, http.StatusInternalServerError, "Failed to delete subscription", err)
-		}
+		response.RespondWithError(w, r, err)
 		return
 	}
 
-	response.RespondWithJSON(w, http.StatusNoContent, nil)
+	response.RespondWithJSON(w, r, http.StatusNoContent, nil)
 }
 
-// UpdateSubscription godoc
-// @Summary Обновить подписку
-// @Description Обновляет данные подписки
-// @Tags Subscriptions
-// @Accept json
-// @Produce json
-// @Param subscriptionId path string true "ID подписки"
-// @Param input body dto.UpdateSubscriptionRequest true "Обновленные данные"
-// @Success 200 {object} dto.ResponseSubscription
-// @Failure 400 {object} response.BadRequestError
-// @Failure 404 {object} response.BadRequestError
-// @Failure 500 {object} response.InternalServerError
-// @Router /subscriptions/{subscriptionId} [put]
+// RestoreSubscription восстанавливает удалённую подписку
+func (h *SubscriptionHandler) RestoreSubscription(w http.ResponseWriter, r *http.Request) {
+	subscriptionResponse, err := h.usecase.RestoreSubscription(r.Context(), r.PathValue("subscriptionId"))
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	setETag(w, subscriptionResponse.Version)
+	response.RespondWithJSON(w, r, http.StatusOK, subscriptionResponse)
+}
+
+// ReplaceSubscription полностью заменяет данные подписки
+func (h *SubscriptionHandler) ReplaceSubscription(w http.ResponseWriter, r *http.Request) {
+	subscriptionId := r.PathValue("subscriptionId")
+
+	var req dto.RequestSubscription
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.RespondWithError(w, r, dto.ErrInvalidBody)
+		return
+	}
+
+	if err := validation.Struct(&req); err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	ifMatch, ok := h.ifMatch(w, r)
+	if !ok {
+		return
+	}
+
+	subscriptionResponse, err := h.usecase.ReplaceSubscription(r.Context(), subscriptionId, req, ifMatch)
+	if err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	setETag(w, subscriptionResponse.Version)
+	response.RespondWithJSON(w, r, http.StatusOK, subscriptionResponse)
+}
+
+// UpdateSubscription обновляет подписку по JSON Merge Patch (RFC 7396)
 func (h *SubscriptionHandler) UpdateSubscription(w http.ResponseWriter, r *http.Request) {
 	subscriptionId := r.PathValue("subscriptionId")
 
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != MergePatchContentType && mediaType != "application/json" {
+		response.RespondWithError(w, r, fmt.Errorf("%w: use %s", dto.ErrUnsupportedMediaType, MergePatchContentType))
+		return
+	}
+
 	var req dto.UpdateSubscriptionRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.RespondWithError(w, http.StatusBadRequest, "Invalid request payload", err)
+		response.RespondWithError(w, r, dto.ErrInvalidBody)
+		return
+	}
+
+	if err := validation.Struct(&req); err != nil {
+		response.RespondWithError(w, r, err)
 		return
 	}
 
-	if req.ServiceName == "" && req.Price == 0 && req.EndDate == "" {
-		response.RespondWithError(w, http.StatusBadRequest, "At least one field must be provided", nil)
+	ifMatch, ok := h.ifMatch(w, r)
+	if !ok {
 		return
 	}
 
-	subscriptionResponse, err := h.usecase.UpdateSubscription(subscriptionId, req)
+	subscriptionResponse, err := h.usecase.UpdateSubscription(r.Context(), subscriptionId, req, ifMatch)
 	if err != nil {
-		switch err {
-		case dto.ErrRecordNotFound:
-			response.RespondWithError(w, http.StatusNotFound, "Subscription not found", err)
-		case dto.ErrInvalidID:
-			response.RespondWithError(w, http.StatusBadRequest, "Invalid subscription ID format", err)
-		case dto.ErrInvalidFormat:
-			response.RespondWithError(w, http.StatusBadRequest, "Invalid end_date format. Use MM-YYYY (e.g. '12-2025')", err)
-		default:
-			response.RespondWithError(w, http.StatusInternalServerError, "Failed to update subscription", err)
-		}
+		response.RespondWithError(w, r, err)
 		return
 	}
 
-	response.RespondWithJSON(w, http.StatusOK, subscriptionResponse)
+	setETag(w, subscriptionResponse.Version)
+	response.RespondWithJSON(w, r, http.StatusOK, subscriptionResponse)
 }
 
-// @Tags Analytics
-// @Summary Рассчитать стоимость подписок
-// @Description Возвращает суммарную стоимость подписок за период с фильтрацией
-// @ID calculate-subscriptions-cost
-// @Produce json
-// @Param user_id query string true "UUID пользователя" format(uuid)
-// @Param service_name query string false "Название сервиса"
-// @Param start_date query string true "Начало периода (MM-YYYY)" example(01-2023)
-// @Param end_date query string true "Конец периода (MM-YYYY)" example(12-2023)
-// @Success 200 {object} dto.TotalCostResponse
-// @Failure 400 {object} response.BadRequestError
-// @Failure 500 {object} response.InternalServerError
-// @Router /api/subscriptions/total [get]
+// CalculateSubscriptionsCost возвращает суммарную стоимость подписок за период
 func (h *SubscriptionHandler) CalculateSubscriptionsCost(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query()
-	req := dto.TotalCostRequest{
-		UserID:      q.Get("user_id"),
-		ServiceName: q.Get("service_name"),
-		StartDate:   q.Get("start_date"),
-		EndDate:     q.Get("end_date"),
-	}
-
-	if req.UserID == "" || req.StartDate == "" || req.EndDate == "" {
-		response.RespondWithError(w, http.StatusBadRequest, "user_id, start_date and end_date are required", nil)
+	req, ok := totalCostRequest(w, r)
+	if !ok {
 		return
 	}
 
-	responseData, err := h.usecase.CalculateTotalCost(req)
+	responseData, err := h.usecase.CalculateTotalCost(r.Context(), req)
 	if err != nil {
-		switch {
-		case errors.Is(err, dto.ErrInvalidID):
-			response.RespondWithError(w, http.StatusBadRequest, "Invalid user_id format", err)
-		case strings.Contains(err.Error(), "invalid date format"):
-			response.RespondWithError(w, http.StatusBadRequest, err.Error(), err)
-		default:
-			response.RespondWithError(w, http.StatusInternalServerError, "Failed to calculate total cost", err)
-		}
+		response.RespondWithError(w, r, err)
 		return
 	}
 
-	response.RespondWithJSON(w, http.StatusOK, responseData)
+	response.RespondWithJSON(w, r, http.StatusOK, responseData)
 }
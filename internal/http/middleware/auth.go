@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/BabichevDima/subManager/internal/config"
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	APIKeyHeader    = "X-API-Key"
+	RequestIDHeader = "X-Request-ID"
+)
+
+// Authenticate определяет автора запроса по заголовку X-API-Key.
+// Запросы без ключа выполняются от имени анонимного пользователя,
+// неизвестный ключ отклоняется с 401.
+func Authenticate(keys []config.APIKey) Middleware {
+	byKey := make(map[string]config.APIKey, len(keys))
+	for _, key := range keys {
+		byKey[key.Key] = key
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+				key, ok := byKey[apiKey]
+				if !ok {
+					response.RespondWithError(w, r, dto.ErrUnauthorized)
+					return
+				}
+				ctx := reqctx.WithActor(r.Context(), key.Actor, key.Admin)
+				ctx = logger.WithContext(ctx, logger.FromContext(ctx).With(zap.String("actor", key.Actor)))
+				r = r.WithContext(ctx)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !reqctx.IsAdmin(r.Context()) {
+			response.RespondWithError(w, r, dto.ErrForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
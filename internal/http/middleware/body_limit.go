@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+)
+
+// MaxBodySize ограничивает размер тела запроса. Запрос с заведомо большим
+// Content-Length отклоняется с 413 сразу, остальные обрываются при чтении
+// сверх limit. Обработчики могут ставить и более строгие собственные ограничения.
+func MaxBodySize(limit int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				response.RespondWithError(w, r, dto.ErrBodyTooLarge)
+				return
+			}
+
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
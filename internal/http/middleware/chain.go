@@ -0,0 +1,15 @@
+package middleware
+
+import "net/http"
+
+// Middleware оборачивает обработчик
+type Middleware func(next http.Handler) http.Handler
+
+// Chain оборачивает h в middlewares так, что запрос проходит их в порядке перечисления:
+// первая получает запрос первой и последней видит ответ
+func Chain(h http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
@@ -0,0 +1,182 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/models"
+	"github.com/BabichevDima/subManager/internal/repository"
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// заголовки ответа, которые воспроизводятся вместе с телом
+var idempotentHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Idempotency делает POST-запросы с заголовком Idempotency-Key повторяемыми:
+// первый ответ сохраняется на ttl и возвращается на повтор с тем же телом.
+// Повтор ключа с другим запросом отклоняется с 422, а пока первый запрос
+// выполняется — с 409. Ответы 5xx не сохраняются, такой запрос можно повторить.
+// Тело читается в память целиком, но не больше maxBodySize — того же http.max_body_size,
+// что ограничивает запросы в MaxBodySize; 0 снимает ограничение.
+func Idempotency(repo *repository.IdempotencyRepository, ttl time.Duration, maxBodySize int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				response.RespondWithError(w, r, dto.InvalidField(IdempotencyKeyHeader, "must be at most 255 characters"))
+				return
+			}
+
+			reader := r.Body
+			if maxBodySize > 0 {
+				reader = http.MaxBytesReader(w, r.Body, maxBodySize)
+			}
+			body, err := io.ReadAll(reader)
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					response.RespondWithError(w, r, dto.ErrBodyTooLarge)
+					return
+				}
+				response.RespondWithError(w, r, dto.ErrInvalidBody)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := r.Context()
+			entry := &models.IdempotencyKey{
+				Actor:       reqctx.Actor(ctx),
+				Key:         key,
+				RequestHash: requestHash(r, body),
+				ExpiresAt:   time.Now().Add(ttl),
+			}
+
+			stored, err := repo.Reserve(ctx, entry)
+			if err != nil {
+				response.RespondWithError(w, r, err)
+				return
+			}
+
+			if stored != nil {
+				switch {
+				case stored.RequestHash != entry.RequestHash:
+					response.RespondWithError(w, r, dto.ErrIdempotencyKeyReused)
+				case !stored.Completed():
+					response.RespondWithError(w, r, dto.ErrIdempotencyKeyInUse)
+				default:
+					replay(w, r, stored)
+				}
+				return
+			}
+
+			// ключ освобождается и при панике обработчика, поэтому проверка в defer
+			recorder := &responseRecorder{ResponseWriter: w}
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				if err := repo.Release(context.WithoutCancel(ctx), entry.Actor, entry.Key); err != nil {
+					logger.FromContext(ctx).Error("failed to release idempotency key", zap.Error(err))
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status >= http.StatusInternalServerError {
+				return
+			}
+
+			headers := make(map[string]string, len(idempotentHeaders))
+			for _, name := range idempotentHeaders {
+				if value := w.Header().Get(name); value != "" {
+					headers[name] = value
+				}
+			}
+
+			entry.StatusCode = recorder.statusCode()
+			entry.Headers, _ = json.Marshal(headers)
+			entry.Body = recorder.body.Bytes()
+			if err := repo.Complete(context.WithoutCancel(ctx), entry); err != nil {
+				logger.FromContext(ctx).Error("failed to store idempotent response", zap.Error(err))
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// requestHash отличает повтор того же запроса от другого запроса с тем же ключом
+func requestHash(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	io.WriteString(hash, r.Header.Get("Content-Type")+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, r *http.Request, stored *models.IdempotencyKey) {
+	var headers map[string]string
+	if err := json.Unmarshal(stored.Headers, &headers); err != nil {
+		response.RespondWithError(w, r, err)
+		return
+	}
+
+	for name, value := range headers {
+		w.Header().Set(name, value)
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(stored.StatusCode)
+	w.Write(stored.Body)
+}
+
+// responseRecorder пропускает ответ клиенту, запоминая код и тело
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *responseRecorder) statusCode() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
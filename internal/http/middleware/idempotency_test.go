@@ -0,0 +1,254 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/BabichevDima/subManager/internal/repository"
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"github.com/BabichevDima/subManager/pkg/logger"
+)
+
+// таблица из миграций; AutoMigrate не подходит, потому что SQLite не понимает default:now()
+const idempotencyTable = `CREATE TABLE idempotency_keys (
+	actor varchar(255) NOT NULL,
+	key varchar(255) NOT NULL,
+	request_hash varchar(64) NOT NULL,
+	status_code integer NOT NULL DEFAULT 0,
+	headers jsonb,
+	body bytea,
+	created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	expires_at timestamp NOT NULL,
+	PRIMARY KEY (actor, key)
+)`
+
+// idempotencyServer оборачивает handler в Idempotency поверх SQLite; автор запроса
+// берётся из заголовка X-Actor, как его положил бы Auth
+func idempotencyServer(t *testing.T, ttl time.Duration, maxBodySize int64, handler http.Handler) *httptest.Server {
+	t.Helper()
+	logger.L = zap.NewNop()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "idempotency.db")+"?_pragma=busy_timeout(5000)"), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(idempotencyTable).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	idempotent := Idempotency(repository.NewIdempotencyRepository(db), ttl, maxBodySize)(handler)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := reqctx.WithActor(r.Context(), r.Header.Get("X-Actor"), false)
+		idempotent.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+type idempotentResponse struct {
+	status   int
+	body     string
+	replayed bool
+	location string
+}
+
+func post(t *testing.T, server *httptest.Server, actor, key, path, body string) idempotentResponse {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Actor", actor)
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return idempotentResponse{
+		status:   resp.StatusCode,
+		body:     string(data),
+		replayed: resp.Header.Get(IdempotentReplayedHeader) == "true",
+		location: resp.Header.Get("Location"),
+	}
+}
+
+// createHandler отвечает 201 с номером вызова, чтобы отличить повтор от нового выполнения
+func createHandler(calls *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/api/v1/subscriptions/1")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"call":`+strconv.Itoa(int(n))+`}`)
+	})
+}
+
+func TestIdempotency(t *testing.T) {
+	tests := []struct {
+		name string
+		// second — повтор после первого запроса actor=alice, key=k1, path=/items, body={"a":1}
+		actor, key, path, body string
+		wantStatus             int
+		wantReplayed           bool
+		wantCalls              int32
+	}{
+		{"replay", "alice", "k1", "/items", `{"a":1}`, http.StatusCreated, true, 1},
+		{"different body", "alice", "k1", "/items", `{"a":2}`, http.StatusUnprocessableEntity, false, 1},
+		{"different path", "alice", "k1", "/other", `{"a":1}`, http.StatusUnprocessableEntity, false, 1},
+		{"different key", "alice", "k2", "/items", `{"a":1}`, http.StatusCreated, false, 2},
+		{"keys are per actor", "bob", "k1", "/items", `{"a":1}`, http.StatusCreated, false, 2},
+		{"no key", "alice", "", "/items", `{"a":1}`, http.StatusCreated, false, 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := idempotencyServer(t, time.Hour, 0, createHandler(&calls))
+
+			first := post(t, server, "alice", "k1", "/items", `{"a":1}`)
+			if first.status != http.StatusCreated || first.replayed {
+				t.Fatalf("first request: %+v", first)
+			}
+
+			second := post(t, server, tc.actor, tc.key, tc.path, tc.body)
+			if second.status != tc.wantStatus || second.replayed != tc.wantReplayed {
+				t.Fatalf("second request: %+v, want status %d replayed %v", second, tc.wantStatus, tc.wantReplayed)
+			}
+			if tc.wantReplayed && (second.body != first.body || second.location != first.location) {
+				t.Errorf("replayed %+v, want the stored %+v", second, first)
+			}
+			if got := calls.Load(); got != tc.wantCalls {
+				t.Errorf("handler called %d times, want %d", got, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyExpiredKeyIsReused(t *testing.T) {
+	var calls atomic.Int32
+	server := idempotencyServer(t, -time.Second, 0, createHandler(&calls))
+
+	post(t, server, "alice", "k1", "/items", `{"a":1}`)
+	second := post(t, server, "alice", "k1", "/items", `{"a":2}`)
+	if second.status != http.StatusCreated || second.replayed || calls.Load() != 2 {
+		t.Fatalf("request after expiry: %+v after %d calls, want a new execution", second, calls.Load())
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	server := idempotencyServer(t, time.Hour, 0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	done := make(chan idempotentResponse)
+	go func() {
+		done <- post(t, server, "alice", "k1", "/items", `{"a":1}`)
+	}()
+	<-started
+
+	if got := post(t, server, "alice", "k1", "/items", `{"a":1}`); got.status != http.StatusConflict {
+		t.Errorf("concurrent request: %+v, want 409", got)
+	}
+	close(finish)
+	if got := <-done; got.status != http.StatusCreated {
+		t.Errorf("first request: %+v, want 201", got)
+	}
+}
+
+func TestIdempotencyDoesNotStoreServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	server := idempotencyServer(t, time.Hour, 0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	if got := post(t, server, "alice", "k1", "/items", `{"a":1}`); got.status != http.StatusServiceUnavailable {
+		t.Fatalf("first request: %+v, want 503", got)
+	}
+	if got := post(t, server, "alice", "k1", "/items", `{"a":1}`); got.status != http.StatusCreated || got.replayed {
+		t.Fatalf("retry: %+v, want a new execution", got)
+	}
+	if got := post(t, server, "alice", "k1", "/items", `{"a":1}`); got.status != http.StatusCreated || !got.replayed {
+		t.Fatalf("second retry: %+v, want the stored 201", got)
+	}
+}
+
+func TestIdempotencyReleasesKeyOnPanic(t *testing.T) {
+	var calls atomic.Int32
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			panic("boom")
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	recovered := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if recover() != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+		panicking.ServeHTTP(w, r)
+	})
+	server := idempotencyServer(t, time.Hour, 0, recovered)
+
+	if got := post(t, server, "alice", "k1", "/items", `{"a":1}`); got.status != http.StatusInternalServerError {
+		t.Fatalf("first request: %+v, want 500", got)
+	}
+	if got := post(t, server, "alice", "k1", "/items", `{"a":1}`); got.status != http.StatusCreated || got.replayed {
+		t.Fatalf("retry: %+v, want a new execution", got)
+	}
+}
+
+func TestIdempotencyRejectsInvalidRequests(t *testing.T) {
+	var calls atomic.Int32
+	server := idempotencyServer(t, time.Hour, 16, createHandler(&calls))
+
+	tests := []struct {
+		name, key, body string
+		wantStatus      int
+	}{
+		{"body too large", "k1", `{"a":"0123456789abcdef"}`, http.StatusRequestEntityTooLarge},
+		{"key too long", strings.Repeat("k", maxIdempotencyKeyLength+1), `{"a":1}`, http.StatusBadRequest},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := post(t, server, "alice", tc.key, "/items", tc.body); got.status != tc.wantStatus {
+				t.Errorf("got %+v, want status %d", got, tc.wantStatus)
+			}
+		})
+	}
+	if calls.Load() != 0 {
+		t.Errorf("handler called %d times, want 0", calls.Load())
+	}
+}
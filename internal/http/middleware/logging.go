@@ -5,23 +5,42 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"github.com/BabichevDima/subManager/internal/tracing"
+	"github.com/BabichevDima/subManager/pkg/logger"
 )
 
-func RequestLogger(logger *zap.Logger, next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
+// RequestLogger кладёт в контекст логгер запроса с request_id и trace_id, через который
+// пишут обработчики, usecase и репозитории, и логирует итог запроса: код ответа и размер тела
+func RequestLogger(log *zap.Logger) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			reqLog := log.With(
+				zap.String("request_id", reqctx.RequestID(r.Context())),
+				tracing.LogField(r.Context()),
+			)
+			ctx := logger.WithContext(r.Context(), reqLog)
 
-		logger.Info("request started",
-			zap.String("method", r.Method),
-			zap.String("path", r.URL.Path),
-			zap.String("remote_addr", r.RemoteAddr),
-			zap.String("user_agent", r.UserAgent()),
-		)
+			reqLog.Info("request started",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.String("remote_addr", r.RemoteAddr),
+				zap.String("user_agent", r.UserAgent()),
+			)
 
-		next.ServeHTTP(w, r)
+			rw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r.WithContext(ctx))
 
-		logger.Info("request completed",
-			zap.Duration("duration", time.Since(start)),
-		)
-	})
+			reqLog.Info("request completed",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Int("status", rw.statusCode()),
+				zap.Int64("bytes", rw.bytes),
+				zap.Duration("duration", time.Since(start)),
+			)
+		})
+	}
 }
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/metrics"
+)
+
+// Metrics считает запросы и их длительность по шаблону маршрута из mux, а не по пути,
+// чтобы идентификаторы в URL не раздували число рядов
+func Metrics(mux *http.ServeMux) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &statusWriter{ResponseWriter: w}
+
+			next.ServeHTTP(rw, r)
+
+			metrics.ObserveHTTP(r.Method, route(mux, r), rw.statusCode(), time.Since(start))
+		})
+	}
+}
+
+// route шаблон маршрута из mux без метода; запрос мимо маршрутов даёт unmatched
+func route(mux *http.ServeMux, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		pattern = pattern[i+1:]
+	}
+	if pattern == "" {
+		pattern = "unmatched"
+	}
+	return pattern
+}
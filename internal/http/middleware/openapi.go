@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/BabichevDima/subManager/internal/config"
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/openapi"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// ValidateOpenAPI сверяет запросы к описанным в doc операциям с документом и отвечает
+// на несоответствие 400 или 415, не вызывая обработчик. С cfg.ValidateResponses ответы
+// буферизуются и тоже сверяются: расхождение логируется и заменяется ответом 500.
+// Проверка ответов предназначена для тестов, в продакшене её держат выключенной.
+func ValidateOpenAPI(doc *openapi.Document, cfg config.OpenAPIConfig) Middleware {
+	return func(next http.Handler) http.Handler {
+		if !cfg.ValidateRequests && !cfg.ValidateResponses {
+			return next
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/", next)
+		for path, item := range doc.Paths {
+			for method, op := range *item {
+				pattern := strings.ToUpper(method) + " " + path
+				mux.Handle(pattern, validateOperation(doc, op, pattern, cfg, next))
+			}
+		}
+		return mux
+	}
+}
+
+func validateOperation(doc *openapi.Document, op *openapi.Operation, pattern string, cfg config.OpenAPIConfig, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cfg.ValidateRequests {
+			if err := doc.ValidateRequest(op, r); err != nil {
+				response.RespondWithError(w, r, err)
+				return
+			}
+		}
+
+		if !cfg.ValidateResponses || streams(op) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rec := &bufferedResponse{header: make(http.Header)}
+		next.ServeHTTP(rec, r)
+
+		if err := doc.ValidateResponse(op, rec.statusCode(), rec.header, rec.body.Bytes()); err != nil {
+			logger.FromContext(r.Context()).Error("response does not match OpenAPI document",
+				zap.String("operation", pattern),
+				zap.Int("status", rec.statusCode()),
+				zap.Error(err),
+			)
+			response.RespondWithError(w, r, dto.ErrInternal)
+			return
+		}
+
+		rec.writeTo(w)
+	})
+}
+
+// streams сообщает, что операция отвечает потоком: text/event-stream не заканчивается,
+// а выгрузки (они же отдают application/x-ndjson) пишутся по мере чтения базы и могут
+// не поместиться в память, поэтому такие ответы не буферизуются и не сверяются с документом
+func streams(op *openapi.Operation) bool {
+	reply, ok := op.Responses["200"]
+	if !ok {
+		return false
+	}
+	for _, contentType := range []string{"text/event-stream", "application/x-ndjson"} {
+		if _, ok := reply.Content[contentType]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponse накапливает ответ целиком, чтобы проверить его до отправки клиенту
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *bufferedResponse) Header() http.Header {
+	return rw.header
+}
+
+func (rw *bufferedResponse) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+}
+
+// Write, как и net/http, не принимает тело для 204 и 304
+func (rw *bufferedResponse) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	if rw.status == http.StatusNoContent || rw.status == http.StatusNotModified {
+		return 0, http.ErrBodyNotAllowed
+	}
+	return rw.body.Write(b)
+}
+
+func (rw *bufferedResponse) statusCode() int {
+	if rw.status == 0 {
+		return http.StatusOK
+	}
+	return rw.status
+}
+
+func (rw *bufferedResponse) writeTo(w http.ResponseWriter) {
+	for name, values := range rw.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(rw.statusCode())
+	w.Write(rw.body.Bytes())
+}
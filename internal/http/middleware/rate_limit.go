@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/ratelimit"
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// RateLimit ограничивает частоту запросов клиента по лимиту маршрута. Клиент — автор
+// запроса, определённый Authenticate, затем subject действительного bearer-токена,
+// а анонимный клиент — его IP-адрес. Состояние
+// лимита отдаётся в заголовках RateLimit-*, отказ — 429 с Retry-After. Если хранилище
+// недоступно, запрос пропускается: лимит защищает базу, а не заменяет её.
+// Маршруты exempt (пробы балансировщика, сбор метрик) не ограничиваются никогда:
+// с общего адреса они быстро исчерпали бы корзину и получали 429.
+func RateLimit(mux *http.ServeMux, limiter *ratelimit.Limiter, exempt ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if limiter == nil {
+			return next
+		}
+
+		skip := make(map[string]bool, len(exempt))
+		for _, pattern := range exempt {
+			skip[pattern] = true
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, pattern := mux.Handler(r)
+			limit, ok := limiter.Limit(pattern)
+			if !ok || skip[pattern] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var client string
+			if actor := reqctx.Actor(r.Context()); actor != reqctx.AnonymousActor {
+				client = "actor:" + actor
+			} else if subject := limiter.Subject(r); subject != "" {
+				client = "sub:" + subject
+			} else {
+				client = "ip:" + limiter.ClientIP(r)
+			}
+
+			result, err := limiter.Take(r.Context(), client, limit)
+			if err != nil {
+				logger.FromContext(r.Context()).Warn("rate limit check failed", zap.Error(err))
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+seconds(limit.Period))
+			h.Set("RateLimit-Limit", strconv.Itoa(limit.Capacity()))
+			h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			h.Set("RateLimit-Reset", seconds(result.Reset))
+
+			if !result.Allowed {
+				h.Set("Retry-After", seconds(result.RetryAfter))
+				response.RespondWithError(w, r, dto.ErrRateLimited)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// seconds округляет длительность до целых секунд вверх, как требуют Retry-After и RateLimit-Reset
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Recover перехватывает панику обработчика, пишет её в лог со стеком и отвечает 500
+// problem+json. Если ответ уже начат, соединение обрывается: дописать ошибку в
+// середину тела нельзя. http.ErrAbortHandler пропускается как есть — им обработчик
+// сам просит оборвать ответ. Recover, стоящий до RequestLogger, сам добавляет в лог
+// идентификатор запроса.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &statusWriter{ResponseWriter: w}
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			log := logger.FromContext(r.Context())
+			if log == logger.L {
+				log = log.With(zap.String("request_id", reqctx.RequestID(r.Context())))
+			}
+			log.Error("handler panicked",
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+				zap.Any("panic", recovered),
+				zap.ByteString("stack", debug.Stack()),
+			)
+
+			if rw.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+			response.RespondWithError(rw, r, fmt.Errorf("panic: %v", recovered))
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
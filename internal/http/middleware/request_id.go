@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/BabichevDima/subManager/internal/reqctx"
+)
+
+// RequestID берёт идентификатор запроса из X-Request-ID или создаёт новый и возвращает
+// его в том же заголовке ответа, чтобы клиент мог сослаться на запрос в логах
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := reqctx.NewRequestID(r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, requestID)
+
+		next.ServeHTTP(w, r.WithContext(reqctx.WithRequestID(r.Context(), requestID)))
+	})
+}
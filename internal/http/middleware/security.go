@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/BabichevDima/subManager/internal/config"
+)
+
+// SecurityHeaders добавляет заголовки безопасности. Content-Security-Policy ставится
+// только на страницы веб-интерфейса из app/, которые отдаёт маршрут "/": Swagger UI
+// подключает встроенные скрипты и под той же политикой не работал бы. HSTS
+// отправляется только по TLS, иначе браузер его всё равно игнорирует.
+func SecurityHeaders(mux *http.ServeMux, cfg config.SecurityConfig) Middleware {
+	hsts := "max-age=" + strconv.Itoa(int(cfg.HSTSMaxAge.Seconds()))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			h.Set("X-Frame-Options", "DENY")
+			h.Set("Referrer-Policy", "no-referrer")
+
+			if cfg.ContentSecurityPolicy != "" && route(mux, r) == "/" {
+				h.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+			}
+			if cfg.HSTSMaxAge > 0 && r.TLS != nil {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
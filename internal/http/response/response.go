@@ -2,48 +2,106 @@ package response
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"github.com/BabichevDima/subManager/internal/tracing"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
 )
 
-// Пример для 400 Bad Request
-type BadRequestError struct {
-	Code    int    `json:"code" example:"400"`
-	Message string `json:"message" example:"Bad Request"`
-}
+const (
+	ProblemContentType = "application/problem+json"
+	problemTypePrefix  = "urn:submanager:problem:"
+)
 
-// Пример для 500 Internal Server Error
-type InternalServerError struct {
-	Code    int    `json:"code" example:"500"`
-	Message string `json:"message" example:"Internal Server Error"`
-}
+// HTTP-статусы доменных ошибок по их стабильному коду
+var statusByCode = map[string]int{
+	dto.ErrSubscriptionExists.Code:   http.StatusConflict,
+	dto.ErrInvalidID.Code:            http.StatusBadRequest,
+	dto.ErrInvalidFormat.Code:        http.StatusBadRequest,
+	dto.ErrRecordNotFound.Code:       http.StatusNotFound,
+	dto.ErrNotDeleted.Code:           http.StatusConflict,
+	dto.ErrPreconditionFailed.Code:   http.StatusPreconditionFailed,
+	dto.ErrPreconditionRequired.Code: http.StatusPreconditionRequired,
+	dto.ErrValidation.Code:           http.StatusBadRequest,
+	dto.ErrBatchAborted.Code:         http.StatusFailedDependency,
 
-// ErrSubscriptionExists — ошибка при попытке создать дубликат подписки
-type ErrSubscriptionExists struct {
-	Code    int    `json:"code" example:"409"`
-	Message string `json:"message" example:"Subscription already exists"`
+	dto.ErrInvalidBody.Code:          http.StatusBadRequest,
+	dto.ErrBodyTooLarge.Code:         http.StatusRequestEntityTooLarge,
+	dto.ErrUnsupportedMediaType.Code: http.StatusUnsupportedMediaType,
+	dto.ErrNotAcceptable.Code:        http.StatusNotAcceptable,
+	dto.ErrUnauthorized.Code:         http.StatusUnauthorized,
+	dto.ErrForbidden.Code:            http.StatusForbidden,
+	dto.ErrInvalidToken.Code:         http.StatusForbidden,
+	dto.ErrCalendarDisabled.Code:     http.StatusNotFound,
+	dto.ErrIdempotencyKeyReused.Code: http.StatusUnprocessableEntity,
+	dto.ErrIdempotencyKeyInUse.Code:  http.StatusConflict,
+	dto.ErrRateLimited.Code:          http.StatusTooManyRequests,
+	dto.ErrQueryTooComplex.Code:      http.StatusBadRequest,
 }
 
-func RespondWithError(w http.ResponseWriter, code int, msg string, err error) {
-	if err != nil {
-		log.Println(err)
+// NewProblem описывает ошибку в формате RFC 7807. Статус и код берутся из доменной
+// ошибки dto; любая другая ошибка считается внутренней, и её текст клиенту не отдаётся.
+func NewProblem(r *http.Request, err error) dto.Problem {
+	code := dto.Code(err)
+	status, ok := statusByCode[code]
+	if !ok {
+		code, status = dto.ErrInternal.Code, http.StatusInternalServerError
+	}
+
+	problem := dto.Problem{
+		Type:     problemTypePrefix + code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Code:     code,
+		Detail:   dto.ErrInternal.Message,
+		Instance: reqctx.RequestID(r.Context()),
+		TraceID:  tracing.TraceID(r.Context()),
+	}
+
+	if status < http.StatusInternalServerError {
+		problem.Detail = err.Error()
 	}
-	if code >= http.StatusInternalServerError {
-		log.Printf("Responding with 5XX error: %s", msg)
+
+	var validationErr *dto.ValidationError
+	if errors.As(err, &validationErr) {
+		problem.Errors = validationErr.Fields
 	}
-	type errorResponse struct {
-		Error string `json:"error"`
+
+	return problem
+}
+
+// RespondWithError отвечает application/problem+json по ошибке err
+func RespondWithError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := NewProblem(r, err)
+	if problem.Status >= http.StatusInternalServerError {
+		logger.FromContext(r.Context()).Error("request failed",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Error(err),
+		)
 	}
-	RespondWithJSON(w, code, errorResponse{
-		Error: msg,
-	})
+
+	dat, marshalErr := json.Marshal(problem)
+	if marshalErr != nil {
+		logger.FromContext(r.Context()).Error("failed to marshal problem", zap.Error(marshalErr))
+		w.WriteHeader(500)
+		return
+	}
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(problem.Status)
+	w.Write(dat)
 }
 
-func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+// RespondWithJSON отвечает payload в JSON; ошибка кодирования пишется в лог запроса
+func RespondWithJSON(w http.ResponseWriter, r *http.Request, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	dat, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
+		logger.FromContext(r.Context()).Error("failed to marshal response", zap.Error(err))
 		w.WriteHeader(500)
 		return
 	}
@@ -3,19 +3,345 @@ package router
 import (
 	"net/http"
 
-	_ "github.com/BabichevDima/subManager/internal/docs"
+	"github.com/BabichevDima/subManager/internal/dto"
 	"github.com/BabichevDima/subManager/internal/http/handlers"
+	"github.com/BabichevDima/subManager/internal/http/middleware"
+	"github.com/BabichevDima/subManager/internal/openapi"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
-func RegisterRoutes(mux *http.ServeMux, subscriptionHandler *handlers.SubscriptionHandler) {
-	mux.Handle("/swagger/", httpSwagger.Handler(httpSwagger.URL("/swagger/doc.json")))
+const (
+	tagSubscriptions = "Subscriptions"
+	tagAnalytics     = "Analytics"
+	tagAudit         = "Audit"
+	tagCalendar      = "Calendar"
+)
+
+var (
+	subscriptionID = openapi.InPath("subscriptionId", "ID подписки", openapi.UUID())
+	userID         = openapi.InPath("userId", "UUID пользователя", openapi.UUID())
+	page           = openapi.InQuery("page", "Номер страницы", openapi.Integer(1))
+	pageSize       = openapi.InQuery("pageSize", "Размер страницы, не больше 100", openapi.Integer(10))
+	idempotencyKey = openapi.InHeader(middleware.IdempotencyKeyHeader, "Ключ идемпотентности: повтор с тем же ключом и телом вернёт сохранённый ответ")
+	ifMatch        = openapi.InHeader("If-Match", "ETag подписки; обязателен, если включено concurrency.require_if_match")
+
+	subscriptionReply = func(status int, description string) openapi.Reply {
+		return openapi.Reply{
+			Status:      status,
+			Description: description,
+			Headers:     map[string]string{"ETag": "Версия подписки"},
+			Content:     openapi.JSON(dto.ResponseSubscription{}),
+		}
+	}
+
+	exportContent = map[string]interface{}{
+		"text/csv":             openapi.String(),
+		"application/x-ndjson": openapi.String(),
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": openapi.Binary(),
+	}
+)
+
+// Routes таблица маршрутов API: по ней регистрируются обработчики и строится /openapi.json
+func Routes(
+	subscriptionHandler *handlers.SubscriptionHandler,
+	auditHandler *handlers.AuditHandler,
+	calendarHandler *handlers.CalendarHandler,
+	eventsHandler *handlers.EventsHandler,
+) []openapi.Route {
+	return []openapi.Route{
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/subscriptions",
+			Handler:     http.HandlerFunc(subscriptionHandler.Subscribe),
+			OperationID: "createSubscription",
+			Summary:     "Создать новую подписку",
+			Description: "Создает новую подписку для пользователя",
+			Tags:        []string{tagSubscriptions},
+			Params:      []openapi.Parameter{idempotencyKey},
+			Body:        &openapi.Body{Description: "Данные подписки", Content: openapi.JSON(dto.RequestSubscription{})},
+			Replies:     []openapi.Reply{subscriptionReply(http.StatusCreated, "Подписка создана")},
+			Problems:    []int{http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/subscriptions/import",
+			Handler:     http.HandlerFunc(subscriptionHandler.ImportSubscriptions),
+			OperationID: "importSubscriptions",
+			Summary:     "Импорт подписок из CSV",
+			Description: "Создаёт подписки из CSV-файла в одной транзакции. Первая строка — заголовок со столбцами service_name, price, user_id, start_date и необязательным end_date. " +
+				"Строки проверяются по тем же правилам, что и при создании подписки; дубликаты и некорректные строки пропускаются и попадают в отчёт.",
+			Tags: []string{tagSubscriptions},
+			Params: []openapi.Parameter{
+				openapi.InQuery("delimiter", "Разделитель столбцов (символ или tab)", &openapi.Schema{Type: openapi.Types{"string"}, Default: ","}),
+				openapi.InQuery("date_format", "Формат дат из токенов YYYY, YY, MM, DD", &openapi.Schema{Type: openapi.Types{"string"}, Default: "MM-YYYY"}),
+				openapi.InQuery("dry_run", "Только проверить, ничего не сохраняя", openapi.Boolean(false)),
+				idempotencyKey,
+			},
+			Body: &openapi.Body{
+				Description: "CSV-файл",
+				Content: map[string]interface{}{
+					"text/csv": openapi.String(),
+					"multipart/form-data": &openapi.Schema{
+						Type:       openapi.Types{"object"},
+						Properties: map[string]*openapi.Schema{"file": openapi.Binary()},
+						Required:   []string{"file"},
+					},
+				},
+			},
+			Replies:  []openapi.Reply{{Status: http.StatusOK, Content: openapi.JSON(dto.ImportReport{})}},
+			Problems: []int{http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/subscriptions/batch",
+			Handler:     http.HandlerFunc(subscriptionHandler.BatchSubscriptions),
+			OperationID: "batchSubscriptions",
+			Summary:     "Пакетное создание, обновление и удаление подписок",
+			Description: "Выполняет массив операций create/update/delete по порядку. Данные операций проверяются по тем же правилам, что и в одиночных запросах; data операции update — JSON Merge Patch, как в PATCH. " +
+				"Поле version у update и delete играет роль If-Match и обязательно, если включено concurrency.require_if_match. " +
+				"При atomic=true (по умолчанию) операции выполняются в одной транзакции и ошибка любой из них откатывает все; при atomic=false каждая применяется независимо.",
+			Tags: []string{tagSubscriptions},
+			Params: []openapi.Parameter{
+				openapi.InQuery("atomic", "Выполнить все операции в одной транзакции", openapi.Boolean(true)),
+				idempotencyKey,
+			},
+			Body: &openapi.Body{Description: "Операции", Content: openapi.JSON([]dto.BatchOperation{})},
+			Replies: []openapi.Reply{
+				{Status: http.StatusOK, Description: "Все операции выполнены", Content: openapi.JSON(dto.BatchResponse{})},
+				{Status: http.StatusMultiStatus, Description: "Часть операций завершилась ошибкой", Content: openapi.JSON(dto.BatchResponse{})},
+			},
+			Problems: []int{http.StatusBadRequest, http.StatusConflict, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/subscriptions/{subscriptionId}",
+			Handler:     http.HandlerFunc(subscriptionHandler.GetSubscriptionByID),
+			OperationID: "getSubscription",
+			Summary:     "Получить подписку по ID",
+			Description: "Возвращает детали подписки по её идентификатору",
+			Tags:        []string{tagSubscriptions},
+			Params: []openapi.Parameter{
+				subscriptionID,
+				openapi.InQuery("include_deleted", "Включая удалённые (только для администраторов)", openapi.Boolean(false)),
+				openapi.InHeader("If-None-Match", "ETag, полученный ранее"),
+			},
+			Replies: []openapi.Reply{
+				subscriptionReply(http.StatusOK, "Подписка"),
+				{Status: http.StatusNotModified, Description: "Подписка не изменилась", Headers: map[string]string{"ETag": "Версия подписки"}},
+			},
+			Problems: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/subscriptions",
+			Handler:     http.HandlerFunc(subscriptionHandler.GetAllSubscriptions),
+			OperationID: "listSubscriptions",
+			Summary:     "Получить список подписок",
+			Description: "Возвращает список подписок с пагинацией и фильтрами. Удалённые подписки видны только администраторам",
+			Tags:        []string{tagSubscriptions},
+			Params:      []openapi.Parameter{page, pageSize},
+			Query:       dto.SubscriptionListRequest{},
+			Replies:     []openapi.Reply{{Status: http.StatusOK, Content: openapi.JSON(dto.SubscriptionListResponse{})}},
+			Problems:    []int{http.StatusBadRequest, http.StatusForbidden},
+		},
+		{
+			Method:      http.MethodDelete,
+			Path:        "/api/subscriptions/{subscriptionId}",
+			Handler:     http.HandlerFunc(subscriptionHandler.DeleteSubscription),
+			OperationID: "deleteSubscription",
+			Summary:     "Удалить подписку",
+			Description: "Удаляет подписку по её идентификатору",
+			Tags:        []string{tagSubscriptions},
+			Params:      []openapi.Parameter{subscriptionID, ifMatch},
+			Replies:     []openapi.Reply{{Status: http.StatusNoContent, Description: "Подписка удалена"}},
+			Problems:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusPreconditionFailed, http.StatusPreconditionRequired},
+		},
+		{
+			Method:      http.MethodPut,
+			Path:        "/api/subscriptions/{subscriptionId}",
+			Handler:     http.HandlerFunc(subscriptionHandler.ReplaceSubscription),
+			OperationID: "replaceSubscription",
+			Summary:     "Заменить подписку",
+			Description: "Полностью заменяет данные подписки; обязательны те же поля, что и при создании",
+			Tags:        []string{tagSubscriptions},
+			Params:      []openapi.Parameter{subscriptionID, ifMatch},
+			Body:        &openapi.Body{Description: "Новые данные подписки", Content: openapi.JSON(dto.RequestSubscription{})},
+			Replies:     []openapi.Reply{subscriptionReply(http.StatusOK, "Подписка заменена")},
+			Problems:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusPreconditionRequired},
+		},
+		{
+			Method:      http.MethodPatch,
+			Path:        "/api/subscriptions/{subscriptionId}",
+			Handler:     http.HandlerFunc(subscriptionHandler.UpdateSubscription),
+			OperationID: "updateSubscription",
+			Summary:     "Частично обновить подписку",
+			Description: `Обновляет подписку по JSON Merge Patch (RFC 7396): отсутствующие поля не меняются, "end_date": null снимает дату окончания. Остальные поля обязательны и не могут быть очищены`,
+			Tags:        []string{tagSubscriptions},
+			Params:      []openapi.Parameter{subscriptionID, ifMatch},
+			Body: &openapi.Body{
+				Description: "Изменяемые поля",
+				Content: map[string]interface{}{
+					handlers.MergePatchContentType: dto.UpdateSubscriptionRequest{},
+					openapi.JSONContentType:        dto.UpdateSubscriptionRequest{},
+				},
+			},
+			Replies:  []openapi.Reply{subscriptionReply(http.StatusOK, "Подписка обновлена")},
+			Problems: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnsupportedMediaType, http.StatusPreconditionRequired},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/subscriptions/total",
+			Handler:     http.HandlerFunc(subscriptionHandler.CalculateSubscriptionsCost),
+			OperationID: "calculateSubscriptionsCost",
+			Summary:     "Рассчитать стоимость подписок",
+			Description: "Возвращает суммарную стоимость подписок за период с фильтрацией",
+			Tags:        []string{tagAnalytics},
+			Query:       dto.TotalCostRequest{},
+			Replies:     []openapi.Reply{{Status: http.StatusOK, Content: openapi.JSON(dto.TotalCostResponse{})}},
+			Problems:    []int{http.StatusBadRequest},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/subscriptions/export",
+			Handler:     http.HandlerFunc(subscriptionHandler.ExportSubscriptions),
+			OperationID: "exportSubscriptions",
+			Summary:     "Выгрузить подписки",
+			Description: "Потоково выгружает подписки с фильтрами списка в CSV, NDJSON или XLSX. Формат выбирается параметром format или заголовком Accept, по умолчанию CSV",
+			Tags:        []string{tagSubscriptions},
+			Params:      []openapi.Parameter{openapi.InQuery("format", "Формат файла", openapi.Enum("csv", "ndjson", "jsonl", "xlsx"))},
+			Query:       dto.SubscriptionListRequest{},
+			Replies:     []openapi.Reply{{Status: http.StatusOK, Content: exportContent}},
+			Problems:    []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotAcceptable},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/subscriptions/total/export",
+			Handler:     http.HandlerFunc(subscriptionHandler.ExportTotalCost),
+			OperationID: "exportTotalCost",
+			Summary:     "Выгрузить подписки из расчёта стоимости",
+			Description: "Потоково выгружает подписки, из которых складывается стоимость за период, в CSV, NDJSON или XLSX",
+			Tags:        []string{tagAnalytics},
+			Params:      []openapi.Parameter{openapi.InQuery("format", "Формат файла", openapi.Enum("csv", "ndjson", "jsonl", "xlsx"))},
+			Query:       dto.TotalCostRequest{},
+			Replies:     []openapi.Reply{{Status: http.StatusOK, Content: exportContent}},
+			Problems:    []int{http.StatusBadRequest, http.StatusNotAcceptable},
+		},
+		{
+			Method:      http.MethodPost,
+			Path:        "/api/subscriptions/{subscriptionId}/restore",
+			Handler:     http.HandlerFunc(subscriptionHandler.RestoreSubscription),
+			OperationID: "restoreSubscription",
+			Summary:     "Восстановить подписку",
+			Description: "Восстанавливает удалённую подписку. Только для администраторов",
+			Tags:        []string{tagSubscriptions},
+			Admin:       true,
+			Params:      []openapi.Parameter{subscriptionID, idempotencyKey},
+			Replies:     []openapi.Reply{subscriptionReply(http.StatusOK, "Подписка восстановлена")},
+			Problems:    []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/subscriptions/{subscriptionId}/history",
+			Handler:     http.HandlerFunc(auditHandler.GetSubscriptionHistory),
+			OperationID: "getSubscriptionHistory",
+			Summary:     "История изменений подписки",
+			Description: "Возвращает журнал изменений подписки, начиная с последних",
+			Tags:        []string{tagAudit},
+			Params:      []openapi.Parameter{subscriptionID, page, pageSize},
+			Replies:     []openapi.Reply{{Status: http.StatusOK, Content: openapi.JSON(dto.AuditListResponse{})}},
+			Problems:    []int{http.StatusBadRequest},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/audit",
+			Handler:     http.HandlerFunc(auditHandler.GetAuditLog),
+			OperationID: "getAuditLog",
+			Summary:     "Журнал изменений всех подписок",
+			Description: "Возвращает журнал изменений с фильтрацией по автору и периоду. Только для администраторов",
+			Tags:        []string{tagAudit},
+			Admin:       true,
+			Params:      []openapi.Parameter{page, pageSize},
+			Query:       dto.AuditLogRequest{},
+			Replies:     []openapi.Reply{{Status: http.StatusOK, Content: openapi.JSON(dto.AuditListResponse{})}},
+			Problems:    []int{http.StatusBadRequest},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/subscriptions/events",
+			Handler:     http.HandlerFunc(eventsHandler.StreamEvents),
+			OperationID: "streamSubscriptionEvents",
+			Summary:     "Поток изменений подписок",
+			Description: "Server-Sent Events о создании, изменении, удалении и восстановлении подписок. Поле id события можно передать в Last-Event-ID при переподключении, " +
+				"чтобы получить пропущенные события, пока они есть в буфере сервера",
+			Tags:  []string{tagSubscriptions},
+			Query: dto.EventStreamRequest{},
+			Params: []openapi.Parameter{
+				openapi.InHeader("Last-Event-ID", "id последнего полученного события"),
+			},
+			Replies: []openapi.Reply{{
+				Status:      http.StatusOK,
+				Description: "Поток событий; data каждого события — SubscriptionEvent в JSON",
+				Content:     map[string]interface{}{handlers.EventStreamContentType: openapi.String()},
+			}},
+			Problems: []int{http.StatusBadRequest},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/users/{userId}/renewals.ics",
+			Handler:     http.HandlerFunc(calendarHandler.GetRenewalsCalendar),
+			OperationID: "getRenewalsCalendar",
+			Summary:     "Календарь продлений",
+			Description: "Возвращает iCalendar (RFC 5545) с ежемесячно повторяющимся событием и напоминанием для каждой действующей подписки пользователя. " +
+				"Доступ по токену из ссылки, которую выдаёт /api/users/{userId}/renewals/link",
+			Tags: []string{tagCalendar},
+			Params: []openapi.Parameter{
+				userID,
+				{Name: "token", In: "query", Description: "Токен доступа к календарю", Required: true, Schema: openapi.String()},
+			},
+			Replies:  []openapi.Reply{{Status: http.StatusOK, Description: "iCalendar", Content: map[string]interface{}{"text/calendar": openapi.String()}}},
+			Problems: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound},
+		},
+		{
+			Method:      http.MethodGet,
+			Path:        "/api/users/{userId}/renewals/link",
+			Handler:     http.HandlerFunc(calendarHandler.GetRenewalsCalendarLink),
+			OperationID: "getRenewalsCalendarLink",
+			Summary:     "Ссылка на календарь продлений",
+			Description: "Возвращает ссылку с токеном на календарь продлений пользователя. Только для администраторов",
+			Tags:        []string{tagCalendar},
+			Admin:       true,
+			Params:      []openapi.Parameter{userID},
+			Replies:     []openapi.Reply{{Status: http.StatusOK, Content: openapi.JSON(dto.CalendarLinkResponse{})}},
+			Problems:    []int{http.StatusBadRequest, http.StatusNotFound},
+		},
+	}
+}
+
+// Document строит документ OpenAPI по таблице маршрутов
+func Document(routes []openapi.Route) *openapi.Document {
+	return openapi.Build(openapi.Info{
+		Title:       "Subscriptions Management API",
+		Version:     "1.0",
+		Description: "API для управления подписками пользователей",
+		Contact:     &openapi.Contact{Name: "API Support", Email: "support@submanager.com"},
+		License:     &openapi.License{Name: "MIT", URL: "https://opensource.org/licenses/MIT"},
+	}, routes)
+}
+
+// RegisterRoutes регистрирует маршруты API, документ /openapi.json и, если swagger,
+// Swagger UI для него. Маршруты с Admin доступны только администраторам.
+func RegisterRoutes(mux *http.ServeMux, routes []openapi.Route, doc *openapi.Document, swagger bool) {
+	mux.Handle("GET /openapi.json", doc.Handler())
+	if swagger {
+		mux.Handle("/swagger/", httpSwagger.Handler(httpSwagger.URL("/openapi.json")))
+	}
 	mux.Handle("/", http.FileServer(http.Dir("./app")))
 
-	mux.Handle("POST /api/subscriptions", http.HandlerFunc(subscriptionHandler.Subscribe))
-	mux.Handle("GET /api/subscriptions/{subscriptionId}", http.HandlerFunc(subscriptionHandler.GetSubscriptionByID))
-	mux.Handle("GET /api/subscriptions", http.HandlerFunc(subscriptionHandler.GetAllSubscriptions))
-	mux.Handle("DELETE /api/subscriptions/{subscriptionId}", http.HandlerFunc(subscriptionHandler.DeleteSubscription))
-	mux.Handle("PUT /api/subscriptions/{subscriptionId}", http.HandlerFunc(subscriptionHandler.UpdateSubscription))
-	mux.Handle("GET /api/subscriptions/total", http.HandlerFunc(subscriptionHandler.CalculateSubscriptionsCost))
+	for _, route := range routes {
+		handler := route.Handler
+		if route.Admin {
+			handler = middleware.RequireAdmin(handler)
+		}
+		mux.Handle(route.Method+" "+route.Path, handler)
+	}
 }
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/repository"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// BusinessRefresher периодически пересчитывает бизнес-показатели, чтобы скрейп
+// /metrics не обращался к базе
+type BusinessRefresher struct {
+	repo     *repository.SubscriptionRepository
+	interval time.Duration
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewBusinessRefresher(repo *repository.SubscriptionRepository, interval time.Duration) *BusinessRefresher {
+	return &BusinessRefresher{
+		repo:     repo,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+func (b *BusinessRefresher) Start(ctx context.Context) {
+	ctx, b.cancel = context.WithCancel(ctx)
+	go b.run(ctx)
+}
+
+func (b *BusinessRefresher) Shutdown(ctx context.Context) error {
+	if b == nil || b.cancel == nil {
+		return nil
+	}
+	b.cancel()
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Running сообщает, что цикл пересчёта запущен и ещё не завершился
+func (b *BusinessRefresher) Running() bool {
+	if b == nil || b.cancel == nil {
+		return false
+	}
+	select {
+	case <-b.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *BusinessRefresher) run(ctx context.Context) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		b.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *BusinessRefresher) refresh(ctx context.Context) {
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	count, spend, err := b.repo.ActiveStats(ctx, month)
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error("business metrics refresh failed", zap.Error(err))
+		}
+		return
+	}
+
+	activeSubscriptions.Set(float64(count))
+	monthlySpend.Set(spend)
+	businessRefreshed.SetToCurrentTime()
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	AuditActionCreated  = "created"
+	AuditActionUpdated  = "updated"
+	AuditActionDeleted  = "deleted"
+	AuditActionRestored = "restored"
+	AuditActionPurged   = "purged"
+)
+
+var ErrAuditAppendOnly = errors.New("audit log is append-only")
+
+type AuditEntry struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	SubscriptionID uuid.UUID `gorm:"type:uuid;not null;index"`
+	Actor          string    `gorm:"type:varchar(100);not null;index"`
+	Action         string    `gorm:"type:varchar(20);not null"`
+	Before         []byte    `gorm:"type:jsonb;null"`
+	After          []byte    `gorm:"type:jsonb;null"`
+	Diff           []byte    `gorm:"type:jsonb;not null"`
+	RequestID      string    `gorm:"type:varchar(100)"`
+	CreatedAt      time.Time `gorm:"type:timestamp;not null;default:now();index"`
+}
+
+func (AuditEntry) TableName() string {
+	return "audit_log"
+}
+
+func (AuditEntry) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+func (AuditEntry) BeforeDelete(*gorm.DB) error {
+	return ErrAuditAppendOnly
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// IdempotencyKey сохранённый ответ на POST-запрос с заголовком Idempotency-Key.
+// Ключи разделены по автору запроса; StatusCode 0 означает, что запрос ещё выполняется.
+type IdempotencyKey struct {
+	Actor       string    `gorm:"type:varchar(255);primaryKey"`
+	Key         string    `gorm:"type:varchar(255);primaryKey"`
+	RequestHash string    `gorm:"type:varchar(64);not null"`
+	StatusCode  int       `gorm:"type:integer;not null;default:0"`
+	Headers     []byte    `gorm:"type:jsonb"`
+	Body        []byte    `gorm:"type:bytea"`
+	CreatedAt   time.Time `gorm:"type:timestamp;not null;default:now()"`
+	ExpiresAt   time.Time `gorm:"type:timestamp;not null;index"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
+
+// Completed сообщает, что ответ на запрос уже сохранён
+func (k *IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
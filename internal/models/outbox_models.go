@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxMessage событие, ожидающее отправки. Частичный индекс idx_outbox_pending
+// по неотправленным сообщениям обслуживает выборку relay, которая ищет более ранние
+// события той же подписки
+type OutboxMessage struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid();index:idx_outbox_pending,priority:3"`
+	EventType   string     `gorm:"type:varchar(100);not null"`
+	AggregateID uuid.UUID  `gorm:"type:uuid;not null;index:idx_outbox_pending,priority:1,where:sent_at IS NULL AND failed_at IS NULL"`
+	Payload     []byte     `gorm:"type:jsonb;not null"`
+	Attempts    int        `gorm:"type:integer;not null;default:0"`
+	LastError   string     `gorm:"type:text"`
+	CreatedAt   time.Time  `gorm:"type:timestamp;not null;default:now();index:idx_outbox_pending,priority:2"`
+	SentAt      *time.Time `gorm:"type:timestamp;null;index"`
+	// NextAttemptAt раньше которого сообщение не выбирается: отсрочка после ошибки
+	// или аренда на время публикации
+	NextAttemptAt time.Time `gorm:"type:timestamp;not null;default:now();index"`
+	// FailedAt момент, когда исчерпаны попытки; такое сообщение больше не отправляется
+	FailedAt *time.Time `gorm:"type:timestamp;null"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox"
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// SchemaMigration версия схемы, до которой база доведена миграциями
+type SchemaMigration struct {
+	Version   int       `gorm:"type:integer;primaryKey;autoIncrement:false"`
+	AppliedAt time.Time `gorm:"type:timestamp;not null;default:now()"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
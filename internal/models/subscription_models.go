@@ -4,15 +4,18 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type Subscription struct {
-	ID          uuid.UUID  `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
-	ServiceName string     `gorm:"type:varchar(100);not null"`
-	Price       int        `gorm:"type:integer;not null"`
-	UserID      uuid.UUID  `gorm:"type:uuid;not null"`
-	StartDate   time.Time  `gorm:"type:date;not null"`
-	EndDate     *time.Time `gorm:"type:date;null"`
-	CreatedAt   time.Time  `gorm:"type:timestamp;not null;default:now()"`
-	UpdatedAt   time.Time  `gorm:"type:timestamp;not null;default:now()"`
+	ID          uuid.UUID      `gorm:"type:uuid;primaryKey;default:gen_random_uuid()"`
+	ServiceName string         `gorm:"type:varchar(100);not null"`
+	Price       int            `gorm:"type:integer;not null"`
+	UserID      uuid.UUID      `gorm:"type:uuid;not null"`
+	StartDate   time.Time      `gorm:"type:date;not null"`
+	EndDate     *time.Time     `gorm:"type:date;null"`
+	Version     int64          `gorm:"type:bigint;not null;default:1"`
+	CreatedAt   time.Time      `gorm:"type:timestamp;not null;default:now()"`
+	UpdatedAt   time.Time      `gorm:"type:timestamp;not null;default:now()"`
+	DeletedAt   gorm.DeletedAt `gorm:"type:timestamp;index"`
 }
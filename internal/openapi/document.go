@@ -0,0 +1,297 @@
+// Package openapi строит документ OpenAPI 3.1 из таблицы маршрутов и типов DTO
+// и проверяет по нему запросы и ответы.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	Version = "3.1.0"
+
+	JSONContentType    = "application/json"
+	ProblemContentType = "application/problem+json"
+
+	apiKeyScheme = "ApiKeyAuth"
+)
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title       string   `json:"title"`
+	Version     string   `json:"version"`
+	Description string   `json:"description,omitempty"`
+	Contact     *Contact `json:"contact,omitempty"`
+	License     *License `json:"license,omitempty"`
+}
+
+type Contact struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+type License struct {
+	Name string `json:"name"`
+	URL  string `json:"url,omitempty"`
+}
+
+type Server struct {
+	URL string `json:"url"`
+}
+
+// PathItem операции пути по методам в нижнем регистре
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Name        string `json:"name,omitempty"`
+	In          string `json:"in,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Route описывает эндпоинт. По таблице маршрутов регистрируются обработчики
+// и строится документ, поэтому описание не расходится с кодом.
+type Route struct {
+	Method      string
+	Path        string // шаблон ServeMux, например /api/subscriptions/{subscriptionId}
+	Handler     http.Handler
+	OperationID string
+	Summary     string
+	Description string
+	Tags        []string
+	// Admin помечает операции, доступные только с ключом администратора
+	Admin  bool
+	Params []Parameter
+	// Query DTO, поля которого описывают query-параметры
+	Query interface{}
+	Body  *Body
+	// Replies успешные ответы; ошибки перечисляются в Problems
+	Replies  []Reply
+	Problems []int
+}
+
+// Body тело запроса: значения Content — образцы DTO или готовые *Schema
+type Body struct {
+	Description string
+	Content     map[string]interface{}
+}
+
+// Reply ответ операции: значения Content — образцы DTO или готовые *Schema
+type Reply struct {
+	Status      int
+	Description string
+	Headers     map[string]string
+	Content     map[string]interface{}
+}
+
+// JSON содержимое application/json с типом v
+func JSON(v interface{}) map[string]interface{} {
+	return map[string]interface{}{JSONContentType: v}
+}
+
+// InPath описывает параметр пути
+func InPath(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "path", Description: description, Required: true, Schema: schema}
+}
+
+// InQuery описывает необязательный query-параметр
+func InQuery(name, description string, schema *Schema) Parameter {
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+// InHeader описывает необязательный заголовок запроса
+func InHeader(name, description string) Parameter {
+	return Parameter{Name: name, In: "header", Description: description, Schema: String()}
+}
+
+// Build строит документ по таблице маршрутов. Типы DTO попадают в components.schemas.
+func Build(info Info, routes []Route) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+			SecuritySchemes: map[string]SecurityScheme{
+				apiKeyScheme: {Type: "apiKey", Name: "X-API-Key", In: "header", Description: "Ключ API; без него запрос выполняется анонимно"},
+			},
+		},
+	}
+
+	for _, route := range routes {
+		item, ok := doc.Paths[route.Path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[route.Path] = item
+		}
+		(*item)[strings.ToLower(route.Method)] = doc.operation(route)
+	}
+
+	return doc
+}
+
+func (d *Document) operation(route Route) *Operation {
+	op := &Operation{
+		OperationID: route.OperationID,
+		Summary:     route.Summary,
+		Description: route.Description,
+		Tags:        route.Tags,
+		Parameters:  append([]Parameter(nil), route.Params...),
+		Responses:   make(map[string]*Response),
+		Security:    []map[string][]string{{}, {apiKeyScheme: {}}},
+	}
+	if route.Admin {
+		op.Security = []map[string][]string{{apiKeyScheme: {}}}
+	}
+
+	if route.Query != nil {
+		op.Parameters = append(op.Parameters, d.queryParameters(reflect.TypeOf(route.Query))...)
+	}
+
+	if route.Body != nil {
+		op.RequestBody = &RequestBody{
+			Description: route.Body.Description,
+			Required:    true,
+			Content:     d.content(route.Body.Content),
+		}
+	}
+
+	for _, reply := range route.Replies {
+		response := &Response{
+			Description: reply.Description,
+			Content:     d.content(reply.Content),
+		}
+		if response.Description == "" {
+			response.Description = http.StatusText(reply.Status)
+		}
+		for name, description := range reply.Headers {
+			if response.Headers == nil {
+				response.Headers = make(map[string]Header)
+			}
+			response.Headers[name] = Header{Description: description, Schema: String()}
+		}
+		op.Responses[strconv.Itoa(reply.Status)] = response
+	}
+
+	problems := append([]int{http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusInternalServerError}, route.Problems...)
+	if route.Admin {
+		problems = append(problems, http.StatusForbidden)
+	}
+	problem := d.schemaOf(reflect.TypeOf(problemSample))
+	for _, status := range problems {
+		op.Responses[strconv.Itoa(status)] = &Response{
+			Description: http.StatusText(status),
+			Content:     map[string]MediaType{ProblemContentType: {Schema: problem}},
+		}
+	}
+
+	return op
+}
+
+func (d *Document) content(content map[string]interface{}) map[string]MediaType {
+	if len(content) == 0 {
+		return nil
+	}
+
+	types := make(map[string]MediaType, len(content))
+	for contentType, v := range content {
+		switch v := v.(type) {
+		case nil:
+			types[contentType] = MediaType{}
+		case *Schema:
+			types[contentType] = MediaType{Schema: v}
+		default:
+			types[contentType] = MediaType{Schema: d.schemaOf(reflect.TypeOf(v))}
+		}
+	}
+	return types
+}
+
+func (d *Document) queryParameters(t reflect.Type) []Parameter {
+	var params []Parameter
+	for _, field := range fields(t) {
+		schema := d.fieldSchema(field)
+		params = append(params, Parameter{
+			Name:     jsonName(field),
+			In:       "query",
+			Required: hasRule(field, "required"),
+			Schema:   schema,
+		})
+	}
+	sort.SliceStable(params, func(i, j int) bool { return params[i].Required && !params[j].Required })
+	return params
+}
+
+// JSON сериализует документ
+func (d *Document) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// Handler отдаёт документ в JSON
+func (d *Document) Handler() http.Handler {
+	body, err := d.JSON()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", JSONContentType)
+		w.Write(body)
+	})
+}
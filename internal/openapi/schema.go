@@ -0,0 +1,247 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/validation"
+	"github.com/google/uuid"
+)
+
+const (
+	schemaRefPrefix = "#/components/schemas/"
+	monthPattern    = `^(0[1-9]|1[0-2])-\d{4}$`
+)
+
+var (
+	problemSample = dto.Problem{}
+
+	timeType     = reflect.TypeOf(time.Time{})
+	uuidType     = reflect.TypeOf(uuid.UUID{})
+	rawJSONType  = reflect.TypeOf(json.RawMessage{})
+	optionalType = reflect.TypeOf((*validation.Optional)(nil)).Elem()
+)
+
+// Schema подмножество JSON Schema 2020-12, которое использует OpenAPI 3.1
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 Types              `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	Description          string             `json:"description,omitempty"`
+}
+
+// Types тип схемы; в 3.1 nullable записывается как ["string", "null"]
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t Types) has(name string) bool {
+	for _, v := range t {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// String схема строки
+func String() *Schema {
+	return &Schema{Type: Types{"string"}}
+}
+
+// UUID схема строки с UUID
+func UUID() *Schema {
+	return &Schema{Type: Types{"string"}, Format: "uuid"}
+}
+
+// Integer схема целого числа со значением по умолчанию
+func Integer(def int) *Schema {
+	return &Schema{Type: Types{"integer"}, Default: def}
+}
+
+// Boolean схема логического значения по умолчанию
+func Boolean(def bool) *Schema {
+	return &Schema{Type: Types{"boolean"}, Default: def}
+}
+
+// Enum схема строки из перечисленных значений
+func Enum(values ...string) *Schema {
+	return &Schema{Type: Types{"string"}, Enum: values}
+}
+
+// Binary схема файла
+func Binary() *Schema {
+	return &Schema{Type: Types{"string"}, Format: "binary"}
+}
+
+// schemaOf возвращает схему типа; именованные структуры регистрируются в components
+// и подставляются ссылкой
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t.Kind() == reflect.Pointer:
+		schema := d.schemaOf(t.Elem())
+		return nullable(schema)
+	case t == timeType:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	case t == uuidType:
+		return UUID()
+	case t == rawJSONType:
+		return &Schema{Type: Types{"object", "null"}}
+	case t.Implements(optionalType):
+		value, _ := t.FieldByName("Value")
+		return nullable(d.schemaOf(value.Type))
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return String()
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: Types{"integer"}}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: Types{"integer"}, Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: Types{"string"}, Format: "byte"}
+		}
+		// nil-срезы и nil-карты encoding/json пишет как null
+		return &Schema{Type: Types{"array", "null"}, Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: Types{"object", "null"}, AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return d.structRef(t)
+	}
+	return &Schema{}
+}
+
+func (d *Document) structRef(t reflect.Type) *Schema {
+	name := t.Name()
+	if name == "" {
+		return d.structSchema(t)
+	}
+
+	if _, ok := d.Components.Schemas[name]; !ok {
+		// заглушка защищает от бесконечной рекурсии на ссылающихся друг на друга типах
+		d.Components.Schemas[name] = &Schema{}
+		*d.Components.Schemas[name] = *d.structSchema(t)
+	}
+	return &Schema{Ref: schemaRefPrefix + name}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: Types{"object"}, Properties: make(map[string]*Schema)}
+	for _, field := range fields(t) {
+		name := jsonName(field)
+		schema.Properties[name] = d.fieldSchema(field)
+		if hasRule(field, "required") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// fieldSchema дополняет схему типа поля ограничениями из тегов validate и enums
+func (d *Document) fieldSchema(field reflect.StructField) *Schema {
+	schema := d.schemaOf(field.Type)
+	if schema.Ref != "" || schema.AnyOf != nil {
+		return schema
+	}
+
+	if field.Type.Implements(optionalType) && hasRule(field, "notnull") {
+		schema.Type = schema.Type[:1]
+	}
+
+	if enums := field.Tag.Get("enums"); enums != "" {
+		schema.Enum = strings.Split(enums, ",")
+	}
+
+	for _, rule := range strings.Split(field.Tag.Get("validate"), ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required", "nonempty":
+			if !schema.Type.has("string") {
+				continue
+			}
+			one := 1
+			schema.MinLength = &one
+		case "gt":
+			limit, _ := strconv.ParseFloat(param, 64)
+			schema.ExclusiveMinimum = &limit
+		case "max":
+			limit, _ := strconv.Atoi(param)
+			schema.MaxLength = &limit
+		case "uuid":
+			schema.Format = "uuid"
+		case "mmyyyy":
+			schema.Pattern = monthPattern
+			schema.Description = "Месяц в формате MM-YYYY"
+		case "timestamp":
+			schema.Description = "Время в RFC 3339 или дата YYYY-MM-DD"
+		case "oneof":
+			schema.Enum = strings.Split(param, " ")
+		}
+	}
+
+	return schema
+}
+
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		return &Schema{AnyOf: []*Schema{schema, {Type: Types{"null"}}}}
+	}
+	if !schema.Type.has("null") && len(schema.Type) > 0 {
+		schema.Type = append(schema.Type, "null")
+	}
+	return schema
+}
+
+func fields(t reflect.Type) []reflect.StructField {
+	var result []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.IsExported() && jsonName(field) != "-" {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func hasRule(field reflect.StructField, rule string) bool {
+	for _, r := range strings.Split(field.Tag.Get("validate"), ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
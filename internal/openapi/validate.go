@@ -0,0 +1,312 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/google/uuid"
+)
+
+// ValidateRequest проверяет параметры и тело запроса по операции. Ошибки полей
+// собираются в один *dto.ValidationError; неописанный Content-Type тела даёт
+// dto.ErrUnsupportedMediaType. Прочитанное тело подставляется обратно в r.Body.
+func (d *Document) ValidateRequest(op *Operation, r *http.Request) error {
+	var errs dto.ValidationError
+
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		var value string
+		switch param.In {
+		case "path":
+			value = r.PathValue(param.Name)
+		case "query":
+			value = query.Get(param.Name)
+		case "header":
+			value = r.Header.Get(param.Name)
+		}
+
+		if value == "" {
+			if param.Required {
+				errs.Add(param.Name, "is required")
+			}
+			continue
+		}
+		d.validateParameter(param.Schema, value, param.Name, &errs)
+	}
+
+	if op.RequestBody != nil {
+		if err := d.validateRequestBody(op.RequestBody, r, &errs); err != nil {
+			return err
+		}
+	}
+
+	return errs.Err()
+}
+
+// ValidateResponse проверяет, что статус, Content-Type и JSON-тело ответа описаны в операции
+func (d *Document) ValidateResponse(op *Operation, status int, header http.Header, body []byte) error {
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+
+	if len(response.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("status %d is documented without a body", status)
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	media, ok := response.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("content type %q is not documented for status %d", mediaType, status)
+	}
+	if media.Schema == nil || !isJSON(mediaType) {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+
+	var errs dto.ValidationError
+	d.validateValue(media.Schema, value, "", &errs)
+	return errs.Err()
+}
+
+func (d *Document) validateRequestBody(body *RequestBody, r *http.Request, errs *dto.ValidationError) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "" {
+		// без Content-Type тело разбирает обработчик; JSON проверяем, если он описан
+		if _, ok := body.Content[JSONContentType]; !ok {
+			return nil
+		}
+		mediaType = JSONContentType
+	}
+
+	media, ok := body.Content[mediaType]
+	if !ok {
+		return dto.ErrUnsupportedMediaType
+	}
+	if media.Schema == nil || !isJSON(mediaType) {
+		return nil
+	}
+
+	data, err := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return dto.ErrInvalidBody
+	}
+
+	d.validateValue(media.Schema, value, "", errs)
+	return nil
+}
+
+// validateParameter приводит строку параметра к типу схемы и проверяет значение
+func (d *Document) validateParameter(schema *Schema, raw, name string, errs *dto.ValidationError) {
+	schema = d.resolve(schema)
+
+	var value interface{} = raw
+	switch {
+	case schema.Type.has("integer"):
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			errs.Add(name, "must be an integer")
+			return
+		}
+		value = float64(n)
+	case schema.Type.has("boolean"):
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			errs.Add(name, "must be a boolean")
+			return
+		}
+		value = b
+	}
+
+	d.validateValue(schema, value, name, errs)
+}
+
+func (d *Document) validateValue(schema *Schema, value interface{}, path string, errs *dto.ValidationError) {
+	schema = d.resolve(schema)
+	field := path
+	if field == "" {
+		field = "body"
+	}
+
+	if len(schema.AnyOf) > 0 {
+		for _, alternative := range schema.AnyOf {
+			alternative = d.resolve(alternative)
+			if value == nil && alternative.Type.has("null") {
+				return
+			}
+			if !alternative.Type.has("null") {
+				d.validateValue(alternative, value, path, errs)
+				return
+			}
+		}
+	}
+
+	switch value := value.(type) {
+	case nil:
+		if len(schema.Type) > 0 && !schema.Type.has("null") {
+			errs.Add(field, "must not be null")
+		}
+	case string:
+		if !allows(schema, "string") {
+			errs.Add(field, typeMessage(schema))
+			return
+		}
+		if msg := checkString(schema, value); msg != "" {
+			errs.Add(field, msg)
+		}
+	case float64:
+		switch {
+		case !allows(schema, "number") && !allows(schema, "integer"):
+			errs.Add(field, typeMessage(schema))
+		case !allows(schema, "number") && value != math.Trunc(value):
+			errs.Add(field, "must be an integer")
+		case schema.ExclusiveMinimum != nil && value <= *schema.ExclusiveMinimum:
+			errs.Add(field, "must be greater than "+strconv.FormatFloat(*schema.ExclusiveMinimum, 'f', -1, 64))
+		}
+	case bool:
+		if !allows(schema, "boolean") {
+			errs.Add(field, typeMessage(schema))
+		}
+	case []interface{}:
+		if !allows(schema, "array") {
+			errs.Add(field, typeMessage(schema))
+			return
+		}
+		if schema.Items != nil {
+			for i, item := range value {
+				d.validateValue(schema.Items, item, fmt.Sprintf("%s[%d]", field, i), errs)
+			}
+		}
+	case map[string]interface{}:
+		if !allows(schema, "object") {
+			errs.Add(field, typeMessage(schema))
+			return
+		}
+		for _, name := range schema.Required {
+			if _, ok := value[name]; !ok {
+				errs.Add(join(path, name), "is required")
+			}
+		}
+		names := make([]string, 0, len(schema.Properties))
+		for name := range schema.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if v, ok := value[name]; ok {
+				d.validateValue(schema.Properties[name], v, join(path, name), errs)
+			}
+		}
+		if schema.AdditionalProperties != nil {
+			for name, v := range value {
+				if _, ok := schema.Properties[name]; !ok {
+					d.validateValue(schema.AdditionalProperties, v, join(path, name), errs)
+				}
+			}
+		}
+	}
+}
+
+func checkString(schema *Schema, value string) string {
+	length := utf8.RuneCountInString(value)
+	switch {
+	case schema.MinLength != nil && length < *schema.MinLength:
+		return "must not be empty"
+	case schema.MaxLength != nil && length > *schema.MaxLength:
+		return fmt.Sprintf("must be at most %d characters", *schema.MaxLength)
+	case len(schema.Enum) > 0 && !contains(schema.Enum, value):
+		return "must be one of " + strings.Join(schema.Enum, ", ")
+	}
+
+	switch schema.Format {
+	case "uuid":
+		if _, err := uuid.Parse(value); err != nil {
+			return "must be a valid UUID"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			return "must be RFC 3339 date-time"
+		}
+	}
+
+	if schema.Pattern != "" {
+		if matched, _ := regexp.MatchString(schema.Pattern, value); !matched {
+			if schema.Pattern == monthPattern {
+				return "must be in MM-YYYY format"
+			}
+			return "must match " + schema.Pattern
+		}
+	}
+	return ""
+}
+
+func (d *Document) resolve(schema *Schema) *Schema {
+	if schema == nil {
+		return &Schema{}
+	}
+	if name, ok := strings.CutPrefix(schema.Ref, schemaRefPrefix); ok {
+		if resolved, ok := d.Components.Schemas[name]; ok {
+			return resolved
+		}
+	}
+	return schema
+}
+
+func allows(schema *Schema, typ string) bool {
+	return len(schema.Type) == 0 || schema.Type.has(typ)
+}
+
+func typeMessage(schema *Schema) string {
+	var types []string
+	for _, typ := range schema.Type {
+		if typ != "null" {
+			types = append(types, typ)
+		}
+	}
+	return "must be of type " + strings.Join(types, " or ")
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == JSONContentType || strings.HasSuffix(mediaType, "+json")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
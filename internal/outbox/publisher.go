@@ -0,0 +1,143 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/config"
+	"github.com/BabichevDima/subManager/internal/models"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Publisher доставляет сообщение outbox во внешний транспорт.
+// Сообщение считается отправленным, только если Publish вернул nil.
+type Publisher interface {
+	Publish(ctx context.Context, msg *models.OutboxMessage) error
+}
+
+// LogPublisher пишет события в лог приложения
+type LogPublisher struct{}
+
+func NewLogPublisher() *LogPublisher {
+	return &LogPublisher{}
+}
+
+func (p *LogPublisher) Publish(ctx context.Context, msg *models.OutboxMessage) error {
+	logger.Info("outbox event published",
+		zap.String("event_id", msg.ID.String()),
+		zap.String("event_type", msg.EventType),
+		zap.ByteString("payload", msg.Payload),
+	)
+	return nil
+}
+
+// HTTPPublisher отправляет события POST-запросом на webhook
+type HTTPPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPPublisher(url string, timeout time.Duration) *HTTPPublisher {
+	return &HTTPPublisher{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *HTTPPublisher) Publish(ctx context.Context, msg *models.OutboxMessage) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(msg.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", msg.ID.String())
+	req.Header.Set("X-Event-Type", msg.EventType)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NATSConn — минимальное подмножество *nats.Conn, нужное для публикации
+type NATSConn interface {
+	Publish(subject string, data []byte) error
+}
+
+// NATSPublisher публикует события в subject вида "<prefix>.<event_type>"
+type NATSPublisher struct {
+	conn   NATSConn
+	prefix string
+}
+
+func NewNATSPublisher(conn NATSConn, prefix string) *NATSPublisher {
+	return &NATSPublisher{conn: conn, prefix: prefix}
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, msg *models.OutboxMessage) error {
+	subject := msg.EventType
+	if p.prefix != "" {
+		subject = p.prefix + "." + msg.EventType
+	}
+	return p.conn.Publish(subject, msg.Payload)
+}
+
+// MemoryBroker — внутрипроцессная замена NATS: синхронно доставляет
+// сообщения подписчикам с точным совпадением subject.
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers map[string][]func(data []byte)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{handlers: make(map[string][]func(data []byte))}
+}
+
+func (b *MemoryBroker) Subscribe(subject string, handler func(data []byte)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[subject] = append(b.handlers[subject], handler)
+}
+
+func (b *MemoryBroker) Publish(subject string, data []byte) error {
+	b.mu.RLock()
+	handlers := b.handlers[subject]
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(data)
+	}
+	return nil
+}
+
+// NewPublisher создаёт Publisher по настройкам outbox. Для "nats" нужно подключение
+// conn: без него события отмечались бы отправленными, никуда не уходя.
+func NewPublisher(cfg config.OutboxConfig, conn NATSConn) (Publisher, error) {
+	switch cfg.Publisher {
+	case "", "log":
+		return NewLogPublisher(), nil
+	case "http":
+		if cfg.HTTPURL == "" {
+			return nil, fmt.Errorf("outbox.http_url is required for http publisher")
+		}
+		return NewHTTPPublisher(cfg.HTTPURL, cfg.HTTPTimeout), nil
+	case "nats":
+		if conn == nil {
+			return nil, fmt.Errorf("outbox.publisher nats requires a NATS connection")
+		}
+		return NewNATSPublisher(conn, cfg.Subject), nil
+	default:
+		return nil, fmt.Errorf("unknown outbox publisher %q", cfg.Publisher)
+	}
+}
@@ -0,0 +1,181 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/config"
+	"github.com/BabichevDima/subManager/internal/models"
+	"github.com/BabichevDima/subManager/internal/repository"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Relay периодически выбирает неотправленные сообщения outbox и публикует их.
+// Неудачная публикация повторяется с растущей отсрочкой, пока не исчерпаны попытки
+type Relay struct {
+	repo      *repository.OutboxRepository
+	publisher Publisher
+	cfg       config.OutboxConfig
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewRelay(repo *repository.OutboxRepository, publisher Publisher, cfg config.OutboxConfig) *Relay {
+	return &Relay{
+		repo:      repo,
+		publisher: publisher,
+		cfg:       cfg,
+		done:      make(chan struct{}),
+	}
+}
+
+func (r *Relay) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	go r.run(ctx)
+}
+
+// Shutdown останавливает relay и ждёт завершения текущей пачки
+func (r *Relay) Shutdown(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+	r.cancel()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Running сообщает, что цикл отправки запущен и ещё не завершился
+func (r *Relay) Running() bool {
+	if r == nil || r.cancel == nil {
+		return false
+	}
+	select {
+	case <-r.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (r *Relay) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.flush(ctx)
+		}
+	}
+}
+
+func (r *Relay) flush(ctx context.Context) {
+	// аренда покрывает публикацию всей пачки, даже если каждая упрётся в таймаут
+	lease := r.cfg.PublishTimeout * time.Duration(r.cfg.BatchSize)
+
+	for ctx.Err() == nil {
+		messages, err := r.repo.ClaimPending(ctx, r.cfg.BatchSize, lease)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error("outbox relay failed", zap.Error(err))
+			}
+			return
+		}
+
+		r.publishBatch(ctx, messages)
+
+		if len(messages) < r.cfg.BatchSize {
+			return
+		}
+	}
+}
+
+func (r *Relay) publishBatch(ctx context.Context, messages []models.OutboxMessage) {
+	// результат записывается и после остановки relay: иначе отправленное событие уйдёт
+	// повторно, а невыбранные останутся в аренде до её истечения
+	store := context.WithoutCancel(ctx)
+
+	// подписки, событие которых не отправлено: их следующие события ждут своей очереди
+	failed := make(map[uuid.UUID]bool)
+	var released []uuid.UUID
+
+	for i := range messages {
+		msg := &messages[i]
+		if failed[msg.AggregateID] || ctx.Err() != nil {
+			released = append(released, msg.ID)
+			continue
+		}
+
+		if err := r.publish(ctx, msg); err != nil {
+			failed[msg.AggregateID] = true
+			// публикация прервана остановкой, а не отказом получателя
+			if ctx.Err() != nil {
+				released = append(released, msg.ID)
+				continue
+			}
+			r.markFailed(store, msg, err)
+			continue
+		}
+		if err := r.repo.MarkSent(store, msg.ID); err != nil {
+			logger.Error("outbox mark sent failed", zap.String("event_id", msg.ID.String()), zap.Error(err))
+		}
+	}
+
+	if len(released) > 0 {
+		releaseCtx, cancel := context.WithTimeout(store, r.cfg.PublishTimeout)
+		defer cancel()
+		if err := r.repo.Release(releaseCtx, released); err != nil {
+			logger.Error("outbox release failed", zap.Error(err))
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, msg *models.OutboxMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.PublishTimeout)
+	defer cancel()
+	return r.publisher.Publish(ctx, msg)
+}
+
+func (r *Relay) markFailed(ctx context.Context, msg *models.OutboxMessage, publishErr error) {
+	attempts := msg.Attempts + 1
+	deadLetter := attempts >= r.cfg.MaxAttempts
+	nextAttempt := time.Now().UTC().Add(r.backoff(attempts))
+
+	fields := []zap.Field{
+		zap.String("event_id", msg.ID.String()),
+		zap.String("event_type", msg.EventType),
+		zap.Int("attempts", attempts),
+		zap.Error(publishErr),
+	}
+	if deadLetter {
+		logger.Error("outbox event failed permanently", fields...)
+	} else {
+		logger.Warn("outbox publish failed", append(fields, zap.Time("next_attempt_at", nextAttempt))...)
+	}
+
+	if err := r.repo.MarkFailed(ctx, msg.ID, publishErr, nextAttempt, deadLetter); err != nil {
+		logger.Error("outbox record attempt failed", zap.String("event_id", msg.ID.String()), zap.Error(err))
+	}
+}
+
+// backoff отсрочка перед попыткой attempts+1: retry_backoff, удвоенная за каждую
+// прошлую неудачу, но не больше max_retry_backoff
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.cfg.RetryBackoff
+	for i := 1; i < attempts && delay < r.cfg.MaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.cfg.MaxRetryBackoff)
+}
@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/BabichevDima/subManager/internal/config"
+)
+
+// Limiter выбирает лимит по маршруту и определяет клиента: по bearer-токену или адресу
+type Limiter struct {
+	store   Store
+	def     Limit
+	routes  map[string]Limit
+	proxies []netip.Prefix
+	tokens  *tokenVerifier
+}
+
+func NewLimiter(cfg config.RateLimitConfig, store Store) (*Limiter, error) {
+	l := &Limiter{
+		store:  store,
+		def:    Limit{Name: "default", Requests: cfg.Requests, Period: cfg.Period, Burst: cfg.Burst},
+		routes: make(map[string]Limit, len(cfg.Routes)),
+	}
+	if err := checkLimit(l.def); err != nil {
+		return nil, fmt.Errorf("rate_limit: %w", err)
+	}
+
+	for _, rule := range cfg.Routes {
+		limit := Limit{Name: rule.Route, Requests: rule.Requests, Period: rule.Period, Burst: rule.Burst}
+		if limit.Period == 0 {
+			limit.Period = l.def.Period
+		}
+		if err := checkLimit(limit); err != nil {
+			return nil, fmt.Errorf("rate_limit.routes %q: %w", rule.Route, err)
+		}
+		l.routes[rule.Route] = limit
+	}
+
+	for _, proxy := range cfg.TrustedProxies {
+		prefix, err := parsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("rate_limit.trusted_proxies: %w", err)
+		}
+		l.proxies = append(l.proxies, prefix)
+	}
+
+	tokens, err := newTokenVerifier(cfg.JWT)
+	if err != nil {
+		return nil, fmt.Errorf("rate_limit.jwt: %w", err)
+	}
+	l.tokens = tokens
+
+	return l, nil
+}
+
+// checkLimit допускает Requests 0 — маршрут без ограничений
+func checkLimit(limit Limit) error {
+	if limit.Requests < 0 || limit.Burst < 0 {
+		return fmt.Errorf("requests and burst must not be negative")
+	}
+	if limit.Requests > 0 && limit.Period <= 0 {
+		return fmt.Errorf("period must be positive")
+	}
+	return nil
+}
+
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		return netip.ParsePrefix(value)
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// Limit лимит для шаблона маршрута из ServeMux ("GET /api/subscriptions/total").
+// false означает, что маршрут не ограничен.
+func (l *Limiter) Limit(pattern string) (Limit, bool) {
+	limit, ok := l.routes[pattern]
+	if !ok {
+		limit = l.def
+	}
+	return limit, limit.Requests > 0
+}
+
+// Take списывает токен из корзины клиента client для лимита limit
+func (l *Limiter) Take(ctx context.Context, client string, limit Limit) (Result, error) {
+	return l.store.Take(ctx, limit.Name+"|"+client, limit)
+}
+
+// Subject subject действительного bearer-токена запроса. Пустая строка, если
+// проверка токенов не настроена или токен отсутствует, просрочен или подписан не тем ключом.
+func (l *Limiter) Subject(r *http.Request) string {
+	if l.tokens == nil {
+		return ""
+	}
+	return l.tokens.subject(r)
+}
+
+// ClientIP адрес клиента. X-Forwarded-For учитывается, только если запрос пришёл
+// от доверенного прокси: адреса в заголовке просматриваются справа налево, и первый
+// не принадлежащий доверенным прокси считается адресом клиента.
+func (l *Limiter) ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !l.trusted(addr) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		addr = hop
+		if !l.trusted(hop) {
+			break
+		}
+	}
+	return addr.Unmap().String()
+}
+
+func (l *Limiter) trusted(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range l.proxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/BabichevDima/subManager/internal/config"
+)
+
+func newTestLimiter(t *testing.T, cfg config.RateLimitConfig) *Limiter {
+	t.Helper()
+	if cfg.Period == 0 {
+		cfg.Requests, cfg.Period = 10, time.Minute
+	}
+	limiter, err := NewLimiter(cfg, NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return limiter
+}
+
+func TestClientIP(t *testing.T) {
+	limiter := newTestLimiter(t, config.RateLimitConfig{
+		TrustedProxies: []string{"10.0.0.0/8", "192.168.1.1"},
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer cannot forward", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"trusted single address", "192.168.1.1:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed leftmost entry is ignored", "10.0.0.2:5000", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"chain of trusted proxies", "10.0.0.2:5000", []string{"198.51.100.1, 10.0.0.3", "10.0.0.4"}, "198.51.100.1"},
+		{"all hops trusted", "10.0.0.2:5000", []string{"10.0.0.3"}, "10.0.0.3"},
+		{"garbage stops the walk", "10.0.0.2:5000", []string{"198.51.100.1, not-an-ip"}, "10.0.0.2"},
+		{"trusted proxy without header", "10.0.0.2:5000", nil, "10.0.0.2"},
+		{"ipv4-mapped ipv6 peer", "[::ffff:10.0.0.2]:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"ipv6 client", "10.0.0.2:5000", []string{"2001:db8::1"}, "2001:db8::1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for _, value := range tc.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := limiter.ClientIP(r); got != tc.want {
+				t.Fatalf("ClientIP = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLimitByRoute(t *testing.T) {
+	limiter := newTestLimiter(t, config.RateLimitConfig{
+		Requests: 300,
+		Period:   time.Minute,
+		Burst:    50,
+		Routes: []config.RateLimitRule{
+			{Route: "GET /api/subscriptions/total", Requests: 30, Burst: 5},
+			{Route: "GET /api/subscriptions/events", Requests: 0},
+		},
+	})
+
+	tests := []struct {
+		pattern  string
+		limited  bool
+		requests int
+		period   time.Duration
+	}{
+		{"GET /api/subscriptions", true, 300, time.Minute},
+		{"GET /api/subscriptions/total", true, 30, time.Minute},
+		{"GET /api/subscriptions/events", false, 0, 0},
+	}
+	for _, tc := range tests {
+		limit, limited := limiter.Limit(tc.pattern)
+		if limited != tc.limited || (limited && (limit.Requests != tc.requests || limit.Period != tc.period)) {
+			t.Errorf("%s: got %+v limited=%v", tc.pattern, limit, limited)
+		}
+	}
+}
+
+func TestNewLimiterRejectsInvalidConfig(t *testing.T) {
+	configs := map[string]config.RateLimitConfig{
+		"negative burst":  {Requests: 10, Period: time.Minute, Burst: -1},
+		"zero period":     {Requests: 10},
+		"bad proxy":       {Requests: 10, Period: time.Minute, TrustedProxies: []string{"10.0.0.0/33"}},
+		"missing pem key": {Requests: 10, Period: time.Minute, JWT: config.JWTConfig{PublicKeyFile: "/nonexistent.pem"}},
+	}
+	for name, cfg := range configs {
+		if _, err := NewLimiter(cfg, NewMemoryStore()); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestSubject(t *testing.T) {
+	const secret = "test-secret"
+	limiter := newTestLimiter(t, config.RateLimitConfig{
+		JWT: config.JWTConfig{HMACSecret: secret, Issuer: "auth", Audience: "submanager"},
+	})
+
+	sign := func(method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{"sub": "user-1", "iss": "auth", "aud": "submanager", "exp": time.Now().Add(time.Hour).Unix()}
+	}
+	with := func(key string, value interface{}) jwt.MapClaims {
+		claims := valid()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"valid token", "Bearer " + sign(jwt.SigningMethodHS256, []byte(secret), valid()), "user-1"},
+		{"scheme is case-insensitive", "bearer " + sign(jwt.SigningMethodHS512, []byte(secret), valid()), "user-1"},
+		{"no header", "", ""},
+		{"basic auth", "Basic dXNlcjpwYXNz", ""},
+		{"wrong key", "Bearer " + sign(jwt.SigningMethodHS256, []byte("other"), valid()), ""},
+		{"unsigned token", "Bearer " + sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, valid()), ""},
+		{"expired", "Bearer " + sign(jwt.SigningMethodHS256, []byte(secret), with("exp", time.Now().Add(-time.Minute).Unix())), ""},
+		{"no expiry", "Bearer " + sign(jwt.SigningMethodHS256, []byte(secret), with("exp", nil)), ""},
+		{"other issuer", "Bearer " + sign(jwt.SigningMethodHS256, []byte(secret), with("iss", "elsewhere")), ""},
+		{"other audience", "Bearer " + sign(jwt.SigningMethodHS256, []byte(secret), with("aud", "billing")), ""},
+		{"garbage", "Bearer not.a.token", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+			if got := limiter.Subject(r); got != tc.want {
+				t.Fatalf("Subject = %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	unconfigured := newTestLimiter(t, config.RateLimitConfig{})
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Authorization", "Bearer "+sign(jwt.SigningMethodHS256, []byte(secret), valid()))
+	if got := unconfigured.Subject(r); got != "" {
+		t.Fatalf("Subject without a configured key = %q, want empty", got)
+	}
+}
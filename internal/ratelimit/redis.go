@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisClient команда EVAL клиента Redis или совместимого хранилища (Valkey, KeyDB).
+// Для go-redis достаточно адаптера над client.Eval(ctx, script, keys, args...).Result().
+type RedisClient interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// takeScript тот же GCRA, что и take, выполненный атомарно на стороне Redis.
+// Время в миллисекундах; ключ живёт, пока корзина не станет полной.
+const takeScript = `
+local now = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local tolerance = tonumber(ARGV[3])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then tat = now end
+local new_tat = tat + interval
+local wait = new_tat - now - tolerance
+if wait > 0 then
+	return {0, tat - now, wait}
+end
+redis.call('SET', KEYS[1], new_tat, 'PX', new_tat - now)
+return {1, new_tat - now, 0}
+`
+
+// RedisStore хранилище корзин в Redis, общее для всех экземпляров приложения
+type RedisStore struct {
+	client RedisClient
+	prefix string
+	now    func() time.Time
+}
+
+func NewRedisStore(client RedisClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix, now: time.Now}
+}
+
+// goRedis адаптер клиента go-redis к RedisClient
+type goRedis struct {
+	client *redis.Client
+}
+
+func (c goRedis) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return c.client.Eval(ctx, script, keys, args...).Result()
+}
+
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	interval := limit.interval()
+	tolerance := interval * time.Duration(limit.Capacity())
+
+	reply, err := s.client.Eval(ctx, takeScript, []string{s.prefix + key},
+		s.now().UnixMilli(),
+		interval.Milliseconds(),
+		tolerance.Milliseconds(),
+	)
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit script failed: %w", err)
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 3 {
+		return Result{}, fmt.Errorf("unexpected rate limit script reply %v", reply)
+	}
+	var ms [3]int64
+	for i, value := range values {
+		if ms[i], ok = value.(int64); !ok {
+			return Result{}, fmt.Errorf("unexpected rate limit script reply %v", reply)
+		}
+	}
+
+	result := Result{
+		Allowed:    ms[0] == 1,
+		Reset:      time.Duration(ms[1]) * time.Millisecond,
+		RetryAfter: time.Duration(ms[2]) * time.Millisecond,
+	}
+	if result.Allowed {
+		result.Remaining = int((tolerance - result.Reset) / interval)
+	}
+	return result, nil
+}
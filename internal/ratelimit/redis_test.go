@@ -0,0 +1,89 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedisStore(t *testing.T) (*RedisStore, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := NewRedisStore(goRedis{client}, "test:")
+	store.now = (&fakeClock{now: time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)}).Now
+	return store, server
+}
+
+// скрипт Redis должен считать так же, как take в памяти
+func TestRedisStoreMatchesMemoryStore(t *testing.T) {
+	limits := []Limit{
+		{Requests: 100, Period: 100 * time.Second, Burst: 5},
+		{Requests: 10, Period: time.Minute},
+		{Requests: 2, Period: time.Second, Burst: 8},
+	}
+
+	for _, limit := range limits {
+		redisStore, _ := newTestRedisStore(t)
+		memoryStore, _ := newTestStore()
+
+		redisAllowed, redisRejected := takeN(t, redisStore, "client", limit, 1000)
+		memoryAllowed, memoryRejected := takeN(t, memoryStore, "client", limit, 1000)
+
+		if redisAllowed != memoryAllowed || redisAllowed != limit.Capacity() {
+			t.Errorf("%+v: redis allowed %d, memory %d, want %d", limit, redisAllowed, memoryAllowed, limit.Capacity())
+		}
+		if redisRejected.RetryAfter != memoryRejected.RetryAfter {
+			t.Errorf("%+v: redis retry after %v, memory %v", limit, redisRejected.RetryAfter, memoryRejected.RetryAfter)
+		}
+	}
+}
+
+func TestRedisStoreRemainingAndExpiry(t *testing.T) {
+	store, server := newTestRedisStore(t)
+	limit := Limit{Requests: 60, Period: time.Minute, Burst: 10}
+
+	result, err := store.Take(context.Background(), "client", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Allowed || result.Remaining != 69 || result.Reset != time.Second {
+		t.Fatalf("first request: %+v, want allowed with 69 remaining and reset 1s", result)
+	}
+
+	// ключ живёт, пока корзина не станет полной
+	if ttl := server.TTL("test:client"); ttl != time.Second {
+		t.Fatalf("key ttl %v, want 1s", ttl)
+	}
+	server.FastForward(time.Second)
+	if server.Exists("test:client") {
+		t.Fatal("key of a full bucket was not expired")
+	}
+}
+
+type replyClient struct {
+	reply interface{}
+}
+
+func (c replyClient) Eval(context.Context, string, []string, ...interface{}) (interface{}, error) {
+	return c.reply, nil
+}
+
+func TestRedisStoreRejectsUnexpectedReply(t *testing.T) {
+	replies := []interface{}{
+		nil,
+		"OK",
+		[]interface{}{int64(1), int64(0)},
+		[]interface{}{int64(1), "0", int64(0)},
+	}
+	for _, reply := range replies {
+		store := NewRedisStore(replyClient{reply}, "")
+		if _, err := store.Take(context.Background(), "client", Limit{Requests: 1, Period: time.Second}); err == nil {
+			t.Errorf("reply %#v accepted", reply)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/BabichevDima/subManager/internal/config"
+)
+
+// NewStore создаёт хранилище корзин по rate_limit.store. Возвращённая функция
+// закрывает подключение к Redis при остановке.
+func NewStore(cfg config.RateLimitConfig) (Store, func(context.Context) error, error) {
+	switch cfg.Store {
+	case "", "memory":
+		return NewMemoryStore(), func(context.Context) error { return nil }, nil
+	case "redis":
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		closeClient := func(context.Context) error { return client.Close() }
+		return NewRedisStore(goRedis{client}, cfg.Redis.Prefix), closeClient, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown rate limit store %q", cfg.Store)
+	}
+}
+
+// Limit корзина токенов: Requests запросов за Period с запасом Burst на всплеск.
+// В полной корзине Requests+Burst токенов, поэтому клиент после паузы может сделать
+// столько запросов подряд; токены восстанавливаются равномерно, по одному за Period/Requests.
+type Limit struct {
+	Name     string
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// interval время восстановления одного токена
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Requests)
+}
+
+// Capacity наибольшее число токенов в корзине
+func (l Limit) Capacity() int {
+	return l.Requests + l.Burst
+}
+
+// Result итог списания токена
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // через сколько корзина снова будет полной
+	RetryAfter time.Duration // через сколько появится токен, если запрос отклонён
+}
+
+// Store хранит состояние корзин. Take атомарно списывает один токен из корзины key;
+// хранилище, общее для нескольких экземпляров приложения, даёт общий лимит на всех.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// take списывает токен по алгоритму GCRA: вместо числа токенов хранится tat — момент,
+// к которому корзина станет полной. Возвращает новое значение tat и итог.
+func take(tat, now time.Time, limit Limit) (time.Time, Result) {
+	interval := limit.interval()
+	tolerance := interval * time.Duration(limit.Capacity())
+
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(interval)
+
+	if wait := next.Sub(now) - tolerance; wait > 0 {
+		return tat, Result{Reset: tat.Sub(now), RetryAfter: wait}
+	}
+	return next, Result{
+		Allowed:   true,
+		Remaining: int((tolerance - next.Sub(now)) / interval),
+		Reset:     next.Sub(now),
+	}
+}
+
+const sweepInterval = time.Minute
+
+// MemoryStore хранилище корзин в памяти процесса: лимит считается отдельно для
+// каждого экземпляра приложения
+type MemoryStore struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tats: make(map[string]time.Time),
+		now:  time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	tat, result := take(s.tats[key], now, limit)
+	s.tats[key] = tat
+	return result, nil
+}
+
+// sweep удаляет полные корзины: они неотличимы от отсутствующих
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, tat := range s.tats {
+		if !tat.After(now) {
+			delete(s.tats, key)
+		}
+	}
+}
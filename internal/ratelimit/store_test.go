@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// fakeClock время хранилищ, которое двигает тест
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore()
+	store.now = clock.Now
+	return store, clock
+}
+
+// takeN списывает токены, пока корзина не опустеет, и возвращает число разрешённых
+// запросов и итог первого отклонённого
+func takeN(t *testing.T, store Store, key string, limit Limit, max int) (int, Result) {
+	t.Helper()
+	for i := 0; i < max; i++ {
+		result, err := store.Take(context.Background(), key, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Allowed {
+			return i, result
+		}
+	}
+	t.Fatalf("bucket %q still has tokens after %d requests", key, max)
+	return 0, Result{}
+}
+
+func TestGCRABurst(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      Limit
+		allowed    int
+		retryAfter time.Duration
+	}{
+		{"burst on top of requests", Limit{Requests: 100, Period: 100 * time.Second, Burst: 5}, 105, time.Second},
+		{"no burst", Limit{Requests: 10, Period: time.Minute}, 10, 6 * time.Second},
+		{"burst larger than requests", Limit{Requests: 2, Period: time.Second, Burst: 8}, 10, 500 * time.Millisecond},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store, _ := newTestStore()
+
+			allowed, rejected := takeN(t, store, "client", tc.limit, 1000)
+			if allowed != tc.allowed {
+				t.Fatalf("allowed %d requests in a row, want %d", allowed, tc.allowed)
+			}
+			if rejected.RetryAfter != tc.retryAfter {
+				t.Errorf("retry after %v, want %v", rejected.RetryAfter, tc.retryAfter)
+			}
+			if rejected.Remaining != 0 {
+				t.Errorf("remaining %d after rejection, want 0", rejected.Remaining)
+			}
+		})
+	}
+}
+
+func TestGCRARefill(t *testing.T) {
+	store, clock := newTestStore()
+	limit := Limit{Requests: 60, Period: time.Minute, Burst: 10}
+
+	first, err := store.Take(context.Background(), "client", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !first.Allowed || first.Remaining != 69 || first.Reset != time.Second {
+		t.Fatalf("first request: %+v, want allowed with 69 remaining and reset 1s", first)
+	}
+
+	takeN(t, store, "client", limit, 100)
+
+	// токен восстанавливается раз в Period/Requests
+	clock.now = clock.now.Add(time.Second)
+	if allowed, _ := takeN(t, store, "client", limit, 100); allowed != 1 {
+		t.Fatalf("allowed %d requests after one interval, want 1", allowed)
+	}
+
+	// через Period*(Requests+Burst)/Requests корзина снова полная
+	clock.now = clock.now.Add(70 * time.Second)
+	if allowed, _ := takeN(t, store, "client", limit, 100); allowed != 70 {
+		t.Fatalf("allowed %d requests after a full refill, want 70", allowed)
+	}
+}
+
+func TestGCRAKeysAreIndependent(t *testing.T) {
+	store, _ := newTestStore()
+	limit := Limit{Requests: 3, Period: time.Minute}
+
+	if allowed, _ := takeN(t, store, "a", limit, 10); allowed != 3 {
+		t.Fatalf("client a allowed %d, want 3", allowed)
+	}
+	if allowed, _ := takeN(t, store, "b", limit, 10); allowed != 3 {
+		t.Fatalf("client b allowed %d, want 3", allowed)
+	}
+}
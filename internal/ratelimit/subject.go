@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/BabichevDima/subManager/internal/config"
+)
+
+// tokenVerifier проверяет подпись и сроки bearer-токена. Subject непроверенного
+// токена ключом лимита быть не может: клиент обходил бы лимит, меняя его в каждом запросе.
+type tokenVerifier struct {
+	key    interface{}
+	parser *jwt.Parser
+}
+
+func newTokenVerifier(cfg config.JWTConfig) (*tokenVerifier, error) {
+	var key interface{}
+	var methods []string
+	switch {
+	case cfg.HMACSecret != "":
+		key = []byte(cfg.HMACSecret)
+		methods = []string{"HS256", "HS384", "HS512"}
+	case cfg.PublicKeyFile != "":
+		pem, err := os.ReadFile(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key: %w", err)
+		}
+		if rsaKey, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+			key = rsaKey
+			methods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}
+		} else if ecKey, err := jwt.ParseECPublicKeyFromPEM(pem); err == nil {
+			key = ecKey
+			methods = []string{"ES256", "ES384", "ES512"}
+		} else {
+			return nil, errors.New("public key must be an RSA or ECDSA PEM key")
+		}
+	default:
+		return nil, nil
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithExpirationRequired()}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	return &tokenVerifier{key: key, parser: jwt.NewParser(opts...)}, nil
+}
+
+// subject subject bearer-токена из заголовка Authorization или пустая строка,
+// если токена нет или он недействителен
+func (v *tokenVerifier) subject(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+
+	parsed, err := v.parser.Parse(strings.TrimSpace(token), func(*jwt.Token) (interface{}, error) {
+		return v.key, nil
+	})
+	if err != nil {
+		return ""
+	}
+	subject, err := parsed.Claims.GetSubject()
+	if err != nil {
+		return ""
+	}
+	return subject
+}
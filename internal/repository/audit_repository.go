@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/models"
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"gorm.io/gorm"
+)
+
+// поля, которые меняются при любой записи и не несут смысла в diff
+var auditIgnoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"version":    true,
+}
+
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db}
+}
+
+func (r *AuditRepository) List(ctx context.Context, filter dto.AuditFilter, offset, limit int) ([]models.AuditEntry, int64, error) {
+	var entries []models.AuditEntry
+	var total int64
+
+	query := r.db.WithContext(ctx).Model(&models.AuditEntry{})
+	if filter.SubscriptionID != nil {
+		query = query.Where("subscription_id = ?", *filter.SubscriptionID)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at <= ?", *filter.To)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&entries).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return entries, total, nil
+}
+
+func addAuditEntry(ctx context.Context, tx *gorm.DB, action string, before, after *models.Subscription) error {
+	beforeFields, beforeJSON, err := auditSnapshot(before)
+	if err != nil {
+		return err
+	}
+	afterFields, afterJSON, err := auditSnapshot(after)
+	if err != nil {
+		return err
+	}
+
+	diff := make(map[string]dto.AuditFieldChange)
+	for field := range mergeKeys(beforeFields, afterFields) {
+		if auditIgnoredFields[field] {
+			continue
+		}
+		from, to := beforeFields[field], afterFields[field]
+		fromJSON, _ := json.Marshal(from)
+		toJSON, _ := json.Marshal(to)
+		if string(fromJSON) != string(toJSON) {
+			diff[field] = dto.AuditFieldChange{From: from, To: to}
+		}
+	}
+
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return err
+	}
+
+	subscription := after
+	if subscription == nil {
+		subscription = before
+	}
+
+	return tx.Create(&models.AuditEntry{
+		SubscriptionID: subscription.ID,
+		Actor:          reqctx.Actor(ctx),
+		Action:         action,
+		Before:         beforeJSON,
+		After:          afterJSON,
+		Diff:           diffJSON,
+		RequestID:      reqctx.RequestID(ctx),
+	}).Error
+}
+
+func auditSnapshot(subscription *models.Subscription) (map[string]interface{}, []byte, error) {
+	if subscription == nil {
+		return nil, nil, nil
+	}
+
+	data, err := json.Marshal(dto.FromModel(subscription))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, nil, err
+	}
+
+	return fields, data, nil
+}
+
+func mergeKeys(a, b map[string]interface{}) map[string]struct{} {
+	keys := make(map[string]struct{}, len(a)+len(b))
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db}
+}
+
+// Reserve занимает ключ под новый запрос. Если ключ уже занят и не истёк,
+// ничего не меняет и возвращает сохранённую запись; иначе возвращает nil.
+func (r *IdempotencyRepository) Reserve(ctx context.Context, entry *models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	var existing *models.IdempotencyKey
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("actor = ? AND key = ? AND expires_at <= ?", entry.Actor, entry.Key, time.Now()).
+			Delete(&models.IdempotencyKey{}).
+			Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(entry)
+		if result.Error != nil || result.RowsAffected > 0 {
+			return result.Error
+		}
+
+		existing = &models.IdempotencyKey{}
+		return tx.First(existing, "actor = ? AND key = ?", entry.Actor, entry.Key).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+// Complete сохраняет ответ на запрос, занявший ключ
+func (r *IdempotencyRepository) Complete(ctx context.Context, entry *models.IdempotencyKey) error {
+	return r.db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("actor = ? AND key = ?", entry.Actor, entry.Key).
+		Updates(map[string]interface{}{
+			"status_code": entry.StatusCode,
+			"headers":     entry.Headers,
+			"body":        entry.Body,
+		}).
+		Error
+}
+
+// Release освобождает ключ, запрос по которому не завершился, чтобы его можно было повторить
+func (r *IdempotencyRepository) Release(ctx context.Context, actor, key string) error {
+	return r.db.WithContext(ctx).
+		Where("actor = ? AND key = ? AND status_code = 0", actor, key).
+		Delete(&models.IdempotencyKey{}).
+		Error
+}
+
+// DeleteExpired удаляет до limit ключей, срок хранения которых истёк к now
+func (r *IdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time, limit int) (int, error) {
+	expired := r.db.Model(&models.IdempotencyKey{}).
+		Select("actor, key").
+		Where("expires_at <= ?", now).
+		Limit(limit)
+
+	result := r.db.WithContext(ctx).
+		Where("(actor, key) IN (?)", expired).
+		Delete(&models.IdempotencyKey{})
+
+	return int(result.RowsAffected), result.Error
+}
@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db}
+}
+
+// ClaimPending выбирает до limit сообщений, готовых к отправке, и откладывает их
+// next_attempt_at на lease, чтобы другие экземпляры их не взяли. Строки блокируются
+// только на время выборки: публикация идёт вне транзакции, а сообщение, которое relay
+// не успел отметить, вернётся в выборку по истечении lease. Сообщение не выбирается,
+// пока не отправлено более раннее сообщение той же подписки, — так сохраняется порядок
+// событий подписки, а ошибка одной подписки не задерживает остальные. Сообщения с
+// одинаковым created_at упорядочиваются по id.
+func (r *OutboxRepository) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxMessage, error) {
+	var messages []models.OutboxMessage
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", now).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM outbox earlier
+				WHERE earlier.aggregate_id = outbox.aggregate_id
+					AND earlier.sent_at IS NULL AND earlier.failed_at IS NULL
+					AND (earlier.created_at < outbox.created_at
+						OR earlier.created_at = outbox.created_at AND earlier.id < outbox.id)
+			)`).
+			Order("created_at, id").
+			Limit(limit).
+			Find(&messages).
+			Error
+		if err != nil || len(messages) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(messages))
+		for i := range messages {
+			ids[i] = messages[i].ID
+		}
+		return tx.Model(&models.OutboxMessage{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).
+			Error
+	})
+
+	return messages, err
+}
+
+// MarkSent отмечает сообщение отправленным
+func (r *OutboxRepository) MarkSent(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&models.OutboxMessage{}).
+		Where("id = ?", id).
+		Update("sent_at", time.Now().UTC()).
+		Error
+}
+
+// MarkFailed записывает неудачную попытку: следующая будет не раньше nextAttempt,
+// а с deadLetter сообщение больше не выбирается и ждёт разбора вручную
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, publishErr error, nextAttempt time.Time, deadLetter bool) error {
+	updates := map[string]interface{}{
+		"attempts":        gorm.Expr("attempts + 1"),
+		"last_error":      publishErr.Error(),
+		"next_attempt_at": nextAttempt,
+	}
+	if deadLetter {
+		updates["failed_at"] = time.Now().UTC()
+	}
+	return r.db.WithContext(ctx).Model(&models.OutboxMessage{}).
+		Where("id = ?", id).
+		Updates(updates).
+		Error
+}
+
+// Release снимает аренду с выбранных, но не отправленных сообщений
+func (r *OutboxRepository) Release(ctx context.Context, ids []uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&models.OutboxMessage{}).
+		Where("id IN ? AND sent_at IS NULL", ids).
+		Update("next_attempt_at", time.Now().UTC()).
+		Error
+}
+
+// DeleteSent удаляет до limit сообщений, отправленных раньше before
+func (r *OutboxRepository) DeleteSent(ctx context.Context, before time.Time, limit int) (int, error) {
+	sent := r.db.Model(&models.OutboxMessage{}).
+		Select("id").
+		Where("sent_at < ?", before).
+		Limit(limit)
+
+	result := r.db.WithContext(ctx).
+		Where("id IN (?)", sent).
+		Delete(&models.OutboxMessage{})
+
+	return int(result.RowsAffected), result.Error
+}
+
+func addOutboxMessage(tx *gorm.DB, eventType string, subscription *models.Subscription) error {
+	event := dto.SubscriptionEvent{
+		ID:           uuid.New(),
+		Type:         eventType,
+		Subscription: dto.FromModel(subscription),
+		OccurredAt:   time.Now().UTC(),
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.OutboxMessage{
+		ID:            event.ID,
+		EventType:     eventType,
+		AggregateID:   subscription.ID,
+		Payload:       payload,
+		CreatedAt:     event.OccurredAt,
+		NextAttemptAt: event.OccurredAt,
+	}).Error
+}
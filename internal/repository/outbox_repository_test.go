@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/BabichevDima/subManager/internal/models"
+)
+
+// таблица из миграций; AutoMigrate не подходит, потому что SQLite не понимает default:now()
+const outboxTable = `CREATE TABLE outbox (
+	id uuid PRIMARY KEY,
+	event_type varchar(100) NOT NULL,
+	aggregate_id uuid NOT NULL,
+	payload jsonb NOT NULL,
+	attempts integer NOT NULL DEFAULT 0,
+	last_error text,
+	created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	sent_at timestamp,
+	next_attempt_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	failed_at timestamp
+)`
+
+var (
+	subscriptionA = uuid.MustParse("aaaaaaaa-0000-4000-8000-000000000000")
+	subscriptionB = uuid.MustParse("bbbbbbbb-0000-4000-8000-000000000000")
+)
+
+func newOutboxRepository(t *testing.T) (*OutboxRepository, *gorm.DB) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "outbox.db")+"?_pragma=busy_timeout(5000)"), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(outboxTable).Error; err != nil {
+		t.Fatal(err)
+	}
+	return NewOutboxRepository(db), db
+}
+
+// addMessage добавляет готовое к отправке сообщение, созданное age назад
+func addMessage(t *testing.T, db *gorm.DB, id string, aggregate uuid.UUID, age time.Duration) *models.OutboxMessage {
+	t.Helper()
+	created := time.Now().UTC().Add(-age).Truncate(time.Second)
+	message := &models.OutboxMessage{
+		ID:            uuid.MustParse(id),
+		EventType:     "subscription.updated",
+		AggregateID:   aggregate,
+		Payload:       []byte(`{}`),
+		CreatedAt:     created,
+		NextAttemptAt: created,
+	}
+	if err := db.Create(message).Error; err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func claimedIDs(t *testing.T, repo *OutboxRepository, limit int) []string {
+	t.Helper()
+	messages, err := repo.ClaimPending(context.Background(), limit, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID.String()[:8]
+	}
+	return ids
+}
+
+func equalIDs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestClaimPendingOrder(t *testing.T) {
+	repo, db := newOutboxRepository(t)
+	ctx := context.Background()
+
+	// a1 и a2 созданы в одну секунду, их порядок задаёт id
+	addMessage(t, db, "a2000000-0000-4000-8000-000000000000", subscriptionA, 3*time.Minute)
+	addMessage(t, db, "a1000000-0000-4000-8000-000000000000", subscriptionA, 3*time.Minute)
+	addMessage(t, db, "a3000000-0000-4000-8000-000000000000", subscriptionA, time.Minute)
+	addMessage(t, db, "b1000000-0000-4000-8000-000000000000", subscriptionB, 2*time.Minute)
+
+	// по одному сообщению на подписку, самые ранние первыми
+	if got, want := claimedIDs(t, repo, 10), []string{"a1000000", "b1000000"}; !equalIDs(got, want) {
+		t.Fatalf("claimed %v, want %v", got, want)
+	}
+	// выбранные сообщения арендованы и не выбираются повторно
+	if got := claimedIDs(t, repo, 10); len(got) != 0 {
+		t.Fatalf("claimed leased messages %v", got)
+	}
+
+	// следующее сообщение подписки доступно только после отправки предыдущего
+	if err := repo.MarkSent(ctx, uuid.MustParse("a1000000-0000-4000-8000-000000000000")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := claimedIDs(t, repo, 10), []string{"a2000000"}; !equalIDs(got, want) {
+		t.Fatalf("claimed %v after a1 was sent, want %v", got, want)
+	}
+}
+
+func TestClaimPendingLimit(t *testing.T) {
+	repo, db := newOutboxRepository(t)
+
+	addMessage(t, db, "a1000000-0000-4000-8000-000000000000", subscriptionA, 2*time.Minute)
+	addMessage(t, db, "b1000000-0000-4000-8000-000000000000", subscriptionB, time.Minute)
+
+	if got, want := claimedIDs(t, repo, 1), []string{"a1000000"}; !equalIDs(got, want) {
+		t.Fatalf("claimed %v, want %v", got, want)
+	}
+	if got, want := claimedIDs(t, repo, 1), []string{"b1000000"}; !equalIDs(got, want) {
+		t.Fatalf("claimed %v, want %v", got, want)
+	}
+}
+
+func TestClaimPendingAfterFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		deadLetter bool
+		want       []string
+	}{
+		// отложенная попытка держит следующие события подписки
+		{"retry later", false, nil},
+		// сообщение, исчерпавшее попытки, больше не задерживает подписку
+		{"dead letter", true, []string{"a2000000"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo, db := newOutboxRepository(t)
+			ctx := context.Background()
+
+			first := addMessage(t, db, "a1000000-0000-4000-8000-000000000000", subscriptionA, 2*time.Minute)
+			addMessage(t, db, "a2000000-0000-4000-8000-000000000000", subscriptionA, time.Minute)
+
+			claimedIDs(t, repo, 10)
+			err := repo.MarkFailed(ctx, first.ID, errors.New("broker unavailable"), time.Now().UTC().Add(time.Hour), tc.deadLetter)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := claimedIDs(t, repo, 10); !equalIDs(got, tc.want) {
+				t.Fatalf("claimed %v, want %v", got, tc.want)
+			}
+
+			var stored models.OutboxMessage
+			if err := db.First(&stored, "id = ?", first.ID).Error; err != nil {
+				t.Fatal(err)
+			}
+			if stored.Attempts != 1 || stored.LastError != "broker unavailable" || (stored.FailedAt != nil) != tc.deadLetter {
+				t.Errorf("stored %+v after failure", stored)
+			}
+		})
+	}
+}
+
+func TestReleaseReturnsMessagesToQueue(t *testing.T) {
+	repo, db := newOutboxRepository(t)
+	ctx := context.Background()
+
+	first := addMessage(t, db, "a1000000-0000-4000-8000-000000000000", subscriptionA, 2*time.Minute)
+	sent := addMessage(t, db, "b1000000-0000-4000-8000-000000000000", subscriptionB, time.Minute)
+
+	claimedIDs(t, repo, 10)
+	if err := repo.MarkSent(ctx, sent.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Release(ctx, []uuid.UUID{first.ID, sent.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := claimedIDs(t, repo, 10), []string{"a1000000"}; !equalIDs(got, want) {
+		t.Fatalf("claimed %v after release, want %v", got, want)
+	}
+}
+
+func TestDeleteSent(t *testing.T) {
+	repo, db := newOutboxRepository(t)
+	ctx := context.Background()
+
+	for _, id := range []string{
+		"a1000000-0000-4000-8000-000000000000",
+		"a2000000-0000-4000-8000-000000000000",
+		"a3000000-0000-4000-8000-000000000000",
+	} {
+		message := addMessage(t, db, id, subscriptionA, time.Hour)
+		if err := repo.MarkSent(ctx, message.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addMessage(t, db, "b1000000-0000-4000-8000-000000000000", subscriptionB, time.Hour)
+
+	// сообщения, отправленные позже before, остаются
+	if deleted, err := repo.DeleteSent(ctx, time.Now().UTC().Add(-time.Minute), 10); err != nil || deleted != 0 {
+		t.Fatalf("deleted %d, %v; want 0", deleted, err)
+	}
+	before := time.Now().UTC().Add(time.Minute)
+	if deleted, err := repo.DeleteSent(ctx, before, 2); err != nil || deleted != 2 {
+		t.Fatalf("deleted %d, %v; want 2", deleted, err)
+	}
+	if deleted, err := repo.DeleteSent(ctx, before, 2); err != nil || deleted != 1 {
+		t.Fatalf("deleted %d, %v; want 1", deleted, err)
+	}
+
+	var pending int64
+	if err := db.Model(&models.OutboxMessage{}).Count(&pending).Error; err != nil || pending != 1 {
+		t.Fatalf("%d messages left, %v; want the unsent one", pending, err)
+	}
+}
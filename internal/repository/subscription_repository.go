@@ -1,12 +1,15 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"github.com/BabichevDima/subManager/internal/dto"
 	"github.com/BabichevDima/subManager/internal/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type SubscriptionRepository struct {
@@ -17,13 +20,28 @@ func NewSubscriptionRepository(db *gorm.DB) *SubscriptionRepository {
 	return &SubscriptionRepository{db}
 }
 
-func (r *SubscriptionRepository) Create(subscription *models.Subscription) error {
-	return r.db.Create(subscription).Error
+// Transaction выполняет fn с репозиторием, привязанным к одной транзакции
+func (r *SubscriptionRepository) Transaction(ctx context.Context, fn func(repo *SubscriptionRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&SubscriptionRepository{db: tx})
+	})
+}
+
+func (r *SubscriptionRepository) Create(ctx context.Context, subscription *models.Subscription) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := claimServiceName(tx, subscription.ServiceName, subscription.UserID, subscription.ID); err != nil {
+			return err
+		}
+		if err := tx.Create(subscription).Error; err != nil {
+			return err
+		}
+		return recordChange(ctx, tx, models.AuditActionCreated, nil, subscription)
+	})
 }
 
-func (r *SubscriptionRepository) Exists(serviceName string, userID uuid.UUID) (bool, error) {
+func (r *SubscriptionRepository) Exists(ctx context.Context, serviceName string, userID uuid.UUID) (bool, error) {
 	var count int64
-	err := r.db.Model(&models.Subscription{}).
+	err := r.db.WithContext(ctx).Model(&models.Subscription{}).
 		Where("service_name = ? AND user_id = ?", serviceName, userID).
 		Count(&count).
 		Error
@@ -34,74 +52,231 @@ func (r *SubscriptionRepository) Exists(serviceName string, userID uuid.UUID) (b
 	return count > 0, nil
 }
 
-func (r *SubscriptionRepository) GetByID(id uuid.UUID) (*models.Subscription, error) {
+// ExistsExcept проверяет, есть ли у пользователя другая подписка на сервис, кроме id
+func (r *SubscriptionRepository) ExistsExcept(ctx context.Context, serviceName string, userID, id uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Subscription{}).
+		Where("service_name = ? AND user_id = ? AND id <> ?", serviceName, userID, id).
+		Count(&count).
+		Error
+
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *SubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID, includeDeleted bool) (*models.Subscription, error) {
 	var subscription models.Subscription
-	err := r.db.First(&subscription, "id = ?", id).Error
+	err := r.scoped(ctx, includeDeleted).First(&subscription, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, dto.ErrRecordNotFound
+	}
 	return &subscription, err
 }
 
-func (r *SubscriptionRepository) GetAll(offset, limit int) ([]models.Subscription, int64, error) {
+func (r *SubscriptionRepository) GetAll(ctx context.Context, filter dto.SubscriptionFilter, offset, limit int) ([]models.Subscription, int64, error) {
 	var subscriptions []models.Subscription
 	var total int64
 
-	if err := r.db.Model(&models.Subscription{}).Count(&total).Error; err != nil {
+	if err := r.filtered(ctx, filter).Count(&total).Error; err != nil {
 		return nil, 0, err
 	}
 
-	if err := r.db.Offset(offset).Limit(limit).Find(&subscriptions).Error; err != nil {
+	query := r.filtered(ctx, filter)
+	if filter.Sort.Field != "" {
+		query = query.
+			Order(clause.OrderByColumn{Column: clause.Column{Name: filter.Sort.Field}, Desc: filter.Sort.Desc}).
+			Order("id")
+	}
+
+	if err := query.Offset(offset).Limit(limit).Find(&subscriptions).Error; err != nil {
 		return nil, 0, err
 	}
 
 	return subscriptions, total, nil
 }
 
-func (r *SubscriptionRepository) Delete(id uuid.UUID) error {
-	result := r.db.Delete(&models.Subscription{}, "id = ?", id)
-	if result.Error != nil {
-		return result.Error
-	}
+// GetByUserIDs возвращает подписки нескольких пользователей одним запросом
+func (r *SubscriptionRepository) GetByUserIDs(ctx context.Context, userIDs []uuid.UUID, includeDeleted bool) ([]models.Subscription, error) {
+	var subscriptions []models.Subscription
+	err := r.scoped(ctx, includeDeleted).
+		Where("user_id IN ?", userIDs).
+		Order("created_at, id").
+		Find(&subscriptions).
+		Error
+	return subscriptions, err
+}
 
-	if result.RowsAffected == 0 {
-		return dto.ErrRecordNotFound
-	}
+// Iterate построчно читает подписки по фильтру, не загружая всю выборку в память
+func (r *SubscriptionRepository) Iterate(ctx context.Context, filter dto.SubscriptionFilter, fn func(sub *models.Subscription) error) error {
+	return iterate(r.filtered(ctx, filter).Order("created_at, id"), fn)
+}
 
-	return nil
+// IterateTotalCost построчно читает подписки, входящие в расчёт стоимости
+func (r *SubscriptionRepository) IterateTotalCost(ctx context.Context, filter dto.TotalCostFilter, fn func(sub *models.Subscription) error) error {
+	return iterate(r.totalCostQuery(ctx, filter).Order("start_date, id"), fn)
 }
 
-func (r *SubscriptionRepository) Update(subscription *models.Subscription) error {
-	result := r.db.Model(subscription).Updates(map[string]interface{}{
-		"service_name": subscription.ServiceName,
-		"price":        subscription.Price,
-		"end_date":     subscription.EndDate,
-		"updated_at":   time.Now(),
+// Delete мягко удаляет подписку, если её версия удовлетворяет ifMatch, и возвращает её
+// в удалённом состоянии
+func (r *SubscriptionRepository) Delete(ctx context.Context, id uuid.UUID, ifMatch dto.IfMatch) (*models.Subscription, error) {
+	var deleted models.Subscription
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockSubscription(tx, id)
+		if err != nil {
+			return err
+		}
+		if !ifMatch.Allows(before.Version) {
+			return dto.ErrPreconditionFailed
+		}
+
+		now := time.Now()
+		result := tx.Model(&models.Subscription{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"deleted_at": now,
+			"version":    gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return dto.ErrRecordNotFound
+		}
+
+		deleted = *before
+		deleted.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		deleted.Version++
+
+		return recordChange(ctx, tx, models.AuditActionDeleted, before, nil)
 	})
 
-	if result.Error != nil {
-		return result.Error
-	}
+	return &deleted, err
+}
 
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
-	}
+func (r *SubscriptionRepository) Restore(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	var restored models.Subscription
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var before models.Subscription
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).First(&before, "id = ?", id).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return dto.ErrRecordNotFound
+			}
+			return err
+		}
 
-	return nil
+		if !before.DeletedAt.Valid {
+			return dto.ErrNotDeleted
+		}
+		if err := claimServiceName(tx, before.ServiceName, before.UserID, before.ID); err != nil {
+			return err
+		}
+
+		err = tx.Unscoped().Model(&models.Subscription{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		restored = before
+		restored.DeletedAt = gorm.DeletedAt{}
+		restored.Version++
+
+		return recordChange(ctx, tx, models.AuditActionRestored, &before, &restored)
+	})
+
+	return &restored, err
+}
+
+// PurgeDeleted безвозвратно удаляет до limit подписок, удалённых раньше deletedBefore
+func (r *SubscriptionRepository) PurgeDeleted(ctx context.Context, deletedBefore time.Time, limit int) (int, error) {
+	purged := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var subscriptions []models.Subscription
+		err := tx.Unscoped().
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+			Limit(limit).
+			Find(&subscriptions).
+			Error
+		if err != nil || len(subscriptions) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(subscriptions))
+		for i := range subscriptions {
+			ids[i] = subscriptions[i].ID
+			if err := addAuditEntry(ctx, tx, models.AuditActionPurged, &subscriptions[i], nil); err != nil {
+				return err
+			}
+		}
+
+		result := tx.Unscoped().Delete(&models.Subscription{}, "id IN ?", ids)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		purged = int(result.RowsAffected)
+		return nil
+	})
+
+	return purged, err
 }
 
-func (r *SubscriptionRepository) CalculateTotalCost(userID uuid.UUID, serviceName string, startDate, endDate time.Time) (float64, int, error) {
+// Update сохраняет подписку, только если её версия в базе совпадает с subscription.Version,
+// и увеличивает версию. Иначе возвращает dto.ErrPreconditionFailed.
+func (r *SubscriptionRepository) Update(ctx context.Context, subscription *models.Subscription) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		before, err := lockSubscription(tx, subscription.ID)
+		if err != nil {
+			return err
+		}
+		if before.Version != subscription.Version {
+			return dto.ErrPreconditionFailed
+		}
+		if err := claimServiceName(tx, subscription.ServiceName, subscription.UserID, subscription.ID); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(subscription).
+			Where("version = ?", subscription.Version).
+			Updates(map[string]interface{}{
+				"service_name": subscription.ServiceName,
+				"price":        subscription.Price,
+				"user_id":      subscription.UserID,
+				"start_date":   subscription.StartDate,
+				"end_date":     subscription.EndDate,
+				"version":      gorm.Expr("version + 1"),
+				"updated_at":   now,
+			})
+
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected == 0 {
+			return dto.ErrPreconditionFailed
+		}
+
+		subscription.Version++
+		subscription.UpdatedAt = now
+
+		return recordChange(ctx, tx, models.AuditActionUpdated, before, subscription)
+	})
+}
+
+func (r *SubscriptionRepository) CalculateTotalCost(ctx context.Context, filter dto.TotalCostFilter) (float64, int, error) {
     var result struct {
         TotalCost float64
         Count     int
     }
 
-    query := r.db.Model(&models.Subscription{}).
-        Select("SUM(price) as total_cost, COUNT(*) as count").
-        Where("user_id = ?", userID).
-        Where("start_date <= ?", endDate).
-        Where("(end_date IS NULL OR end_date >= ?)", startDate)
-
-    if serviceName != "" {
-        query = query.Where("service_name = ?", serviceName)
-    }
+    query := r.totalCostQuery(ctx, filter).
+        Select("SUM(price) as total_cost, COUNT(*) as count")
 
     err := query.Scan(&result).Error
     if err != nil {
@@ -109,4 +284,157 @@ func (r *SubscriptionRepository) CalculateTotalCost(userID uuid.UUID, serviceNam
     }
 
     return result.TotalCost, result.Count, nil
-}
\ No newline at end of file
+}
+
+// CalculateTotalCostByUser считает стоимость подписок каждого пользователя из фильтра
+// одним запросом; пользователи без подписок в результат не попадают
+func (r *SubscriptionRepository) CalculateTotalCostByUser(ctx context.Context, filter dto.TotalCostFilter) (map[uuid.UUID]dto.TotalCostResponse, error) {
+	var rows []struct {
+		UserID    uuid.UUID
+		TotalCost float64
+		Count     int
+	}
+
+	err := r.totalCostQuery(ctx, filter).
+		Select("user_id, SUM(price) as total_cost, COUNT(*) as count").
+		Group("user_id").
+		Scan(&rows).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[uuid.UUID]dto.TotalCostResponse, len(rows))
+	for _, row := range rows {
+		result[row.UserID] = dto.TotalCostResponse{TotalCost: row.TotalCost, SubscriptionsCount: row.Count}
+	}
+	return result, nil
+}
+
+// ActiveStats возвращает число подписок, действующих в месяце month, и их суммарную
+// месячную стоимость
+func (r *SubscriptionRepository) ActiveStats(ctx context.Context, month time.Time) (int64, float64, error) {
+	var result struct {
+		Count int64
+		Spend float64
+	}
+
+	err := r.db.WithContext(ctx).Model(&models.Subscription{}).
+		Where("start_date <= ?", month).
+		Where("(end_date IS NULL OR end_date >= ?)", month).
+		Select("COUNT(*) as count, COALESCE(SUM(price), 0) as spend").
+		Scan(&result).
+		Error
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return result.Count, result.Spend, nil
+}
+
+func (r *SubscriptionRepository) totalCostQuery(ctx context.Context, filter dto.TotalCostFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&models.Subscription{}).
+		Where("user_id IN ?", filter.UserIDs).
+		Where("start_date <= ?", filter.EndDate).
+		Where("(end_date IS NULL OR end_date >= ?)", filter.StartDate)
+
+	if filter.ServiceName != "" {
+		query = query.Where("service_name = ?", filter.ServiceName)
+	}
+
+	return query
+}
+
+func (r *SubscriptionRepository) filtered(ctx context.Context, filter dto.SubscriptionFilter) *gorm.DB {
+	query := r.scoped(ctx, filter.IncludeDeleted).Model(&models.Subscription{})
+
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.ServiceName != "" {
+		query = query.Where("service_name = ?", filter.ServiceName)
+	}
+
+	return query
+}
+
+func iterate(query *gorm.DB, fn func(sub *models.Subscription) error) error {
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var subscription models.Subscription
+		if err := query.ScanRows(rows, &subscription); err != nil {
+			return err
+		}
+		if err := fn(&subscription); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (r *SubscriptionRepository) scoped(ctx context.Context, includeDeleted bool) *gorm.DB {
+	db := r.db.WithContext(ctx)
+	if includeDeleted {
+		return db.Unscoped()
+	}
+	return db
+}
+
+func lockSubscription(tx *gorm.DB, id uuid.UUID) (*models.Subscription, error) {
+	var subscription models.Subscription
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&subscription, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, dto.ErrRecordNotFound
+	}
+	return &subscription, err
+}
+
+// claimServiceName блокирует пару пользователь и сервис до конца транзакции и проверяет,
+// что у пользователя нет другой действующей подписки на сервис. Блокировку берут создание,
+// изменение и восстановление, поэтому между проверкой и записью не вклинится чужая
+// подписка на тот же сервис.
+func claimServiceName(tx *gorm.DB, serviceName string, userID, id uuid.UUID) error {
+	err := tx.Exec("SELECT pg_advisory_xact_lock(hashtextextended(?, 0))", userID.String()+"/"+serviceName).Error
+	if err != nil {
+		return err
+	}
+
+	var count int64
+	err = tx.Model(&models.Subscription{}).
+		Where("service_name = ? AND user_id = ? AND id <> ?", serviceName, userID, id).
+		Count(&count).
+		Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return dto.ErrSubscriptionExists
+	}
+	return nil
+}
+
+// recordChange пишет событие outbox и запись журнала в транзакции изменения подписки
+func recordChange(ctx context.Context, tx *gorm.DB, action string, before, after *models.Subscription) error {
+	eventType := dto.EventSubscriptionUpdated
+	subscription := after
+	switch action {
+	case models.AuditActionCreated:
+		eventType = dto.EventSubscriptionCreated
+	case models.AuditActionRestored:
+		eventType = dto.EventSubscriptionRestored
+	case models.AuditActionDeleted:
+		eventType = dto.EventSubscriptionDeleted
+		subscription = before
+	}
+
+	if err := addOutboxMessage(tx, eventType, subscription); err != nil {
+		return err
+	}
+	return addAuditEntry(ctx, tx, action, before, after)
+}
@@ -0,0 +1,62 @@
+package reqctx
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AnonymousActor = "anonymous"
+
+	maxRequestIDLength = 100
+)
+
+type ctxKey int
+
+const (
+	actorKey ctxKey = iota
+	adminKey
+	requestIDKey
+)
+
+func WithActor(ctx context.Context, actor string, admin bool) context.Context {
+	ctx = context.WithValue(ctx, actorKey, actor)
+	return context.WithValue(ctx, adminKey, admin)
+}
+
+// Actor возвращает идентификатор автора запроса или AnonymousActor
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey).(bool)
+	return admin
+}
+
+// NewRequestID возвращает идентификатор запроса клиента, если он пригоден для логов
+// и журнала аудита, и новый UUID иначе
+func NewRequestID(incoming string) string {
+	if incoming == "" || len(incoming) > maxRequestIDLength {
+		return uuid.NewString()
+	}
+	for i := 0; i < len(incoming); i++ {
+		if incoming[i] < '!' || incoming[i] > '~' {
+			return uuid.NewString()
+		}
+	}
+	return incoming
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
@@ -0,0 +1,88 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/repository"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// KeyCleaner периодически удаляет ключи идемпотентности с истёкшим сроком хранения
+type KeyCleaner struct {
+	repo      *repository.IdempotencyRepository
+	interval  time.Duration
+	batchSize int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewKeyCleaner(repo *repository.IdempotencyRepository, interval time.Duration, batchSize int) *KeyCleaner {
+	return &KeyCleaner{
+		repo:      repo,
+		interval:  interval,
+		batchSize: batchSize,
+		done:      make(chan struct{}),
+	}
+}
+
+func (c *KeyCleaner) Start(ctx context.Context) {
+	ctx, c.cancel = context.WithCancel(ctx)
+	go func() {
+		defer close(c.done)
+		runEvery(ctx, c.interval, c.clean)
+	}()
+}
+
+func (c *KeyCleaner) Shutdown(ctx context.Context) error {
+	if c.cancel == nil {
+		return nil
+	}
+	c.cancel()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Running сообщает, что цикл очистки ключей запущен и ещё не завершился
+func (c *KeyCleaner) Running() bool {
+	if c == nil || c.cancel == nil {
+		return false
+	}
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *KeyCleaner) clean(ctx context.Context) {
+	now := time.Now()
+
+	total := 0
+	for ctx.Err() == nil {
+		deleted, err := c.repo.DeleteExpired(ctx, now, c.batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error("idempotency key cleanup failed", zap.Error(err))
+			}
+			return
+		}
+
+		total += deleted
+		if deleted < c.batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info("deleted expired idempotency keys", zap.Int("count", total))
+	}
+}
@@ -0,0 +1,91 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/repository"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// OutboxCleaner периодически удаляет отправленные сообщения outbox старше retention,
+// чтобы таблица не росла без конца
+type OutboxCleaner struct {
+	repo      *repository.OutboxRepository
+	retention time.Duration
+	interval  time.Duration
+	batchSize int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewOutboxCleaner(repo *repository.OutboxRepository, retention, interval time.Duration, batchSize int) *OutboxCleaner {
+	return &OutboxCleaner{
+		repo:      repo,
+		retention: retention,
+		interval:  interval,
+		batchSize: batchSize,
+		done:      make(chan struct{}),
+	}
+}
+
+func (c *OutboxCleaner) Start(ctx context.Context) {
+	ctx, c.cancel = context.WithCancel(ctx)
+	go func() {
+		defer close(c.done)
+		runEvery(ctx, c.interval, c.clean)
+	}()
+}
+
+func (c *OutboxCleaner) Shutdown(ctx context.Context) error {
+	if c == nil || c.cancel == nil {
+		return nil
+	}
+	c.cancel()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Running сообщает, что цикл очистки outbox запущен и ещё не завершился
+func (c *OutboxCleaner) Running() bool {
+	if c == nil || c.cancel == nil {
+		return false
+	}
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *OutboxCleaner) clean(ctx context.Context) {
+	before := time.Now().UTC().Add(-c.retention)
+
+	total := 0
+	for ctx.Err() == nil {
+		deleted, err := c.repo.DeleteSent(ctx, before, c.batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error("outbox cleanup failed", zap.Error(err))
+			}
+			return
+		}
+
+		total += deleted
+		if deleted < c.batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info("deleted sent outbox messages", zap.Int("count", total))
+	}
+}
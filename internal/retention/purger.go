@@ -0,0 +1,115 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/repository"
+	"github.com/BabichevDima/subManager/internal/reqctx"
+	"github.com/BabichevDima/subManager/pkg/logger"
+	"go.uber.org/zap"
+)
+
+const systemActor = "system:retention"
+
+// Purger периодически безвозвратно удаляет подписки, удалённые дольше срока хранения
+type Purger struct {
+	repo      *repository.SubscriptionRepository
+	retention time.Duration
+	interval  time.Duration
+	batchSize int
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewPurger(repo *repository.SubscriptionRepository, retention, interval time.Duration, batchSize int) *Purger {
+	return &Purger{
+		repo:      repo,
+		retention: retention,
+		interval:  interval,
+		batchSize: batchSize,
+		done:      make(chan struct{}),
+	}
+}
+
+func (p *Purger) Start(ctx context.Context) {
+	ctx, p.cancel = context.WithCancel(reqctx.WithActor(ctx, systemActor, true))
+	go p.run(ctx)
+}
+
+// Shutdown останавливает очистку; безопасен для nil, если очистка отключена
+func (p *Purger) Shutdown(ctx context.Context) error {
+	if p == nil || p.cancel == nil {
+		return nil
+	}
+	p.cancel()
+
+	select {
+	case <-p.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Running сообщает, что цикл очистки запущен и ещё не завершился
+func (p *Purger) Running() bool {
+	if p == nil || p.cancel == nil {
+		return false
+	}
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (p *Purger) run(ctx context.Context) {
+	defer close(p.done)
+	runEvery(ctx, p.interval, p.purge)
+}
+
+// runEvery вызывает fn сразу и затем с интервалом, пока не отменён ctx
+func runEvery(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		fn(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context) {
+	cutoff := time.Now().Add(-p.retention)
+
+	total := 0
+	for ctx.Err() == nil {
+		purged, err := p.repo.PurgeDeleted(ctx, cutoff, p.batchSize)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Error("retention purge failed", zap.Error(err))
+			}
+			return
+		}
+
+		total += purged
+		if purged < p.batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		logger.Info("purged deleted subscriptions",
+			zap.Int("count", total),
+			zap.Time("deleted_before", cutoff),
+		)
+	}
+}
@@ -0,0 +1,82 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/repository"
+	"github.com/google/uuid"
+)
+
+type AuditUsecase struct {
+	repo *repository.AuditRepository
+}
+
+func NewAuditUsecase(r *repository.AuditRepository) *AuditUsecase {
+	return &AuditUsecase{repo: r}
+}
+
+func (u *AuditUsecase) GetSubscriptionHistory(ctx context.Context, id string, page, pageSize int) ([]dto.AuditEntryResponse, int64, error) {
+	subscriptionID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, 0, dto.ErrInvalidID
+	}
+
+	return u.list(ctx, dto.AuditFilter{SubscriptionID: &subscriptionID}, page, pageSize)
+}
+
+func (u *AuditUsecase) GetAuditLog(ctx context.Context, req dto.AuditLogRequest, page, pageSize int) ([]dto.AuditEntryResponse, int64, error) {
+	filter := dto.AuditFilter{Actor: req.Actor}
+
+	if req.From != "" {
+		from, err := parseAuditTime(req.From, false)
+		if err != nil {
+			return nil, 0, dto.InvalidField("from", "must be RFC 3339 or YYYY-MM-DD")
+		}
+		filter.From = &from
+	}
+
+	if req.To != "" {
+		to, err := parseAuditTime(req.To, true)
+		if err != nil {
+			return nil, 0, dto.InvalidField("to", "must be RFC 3339 or YYYY-MM-DD")
+		}
+		filter.To = &to
+	}
+
+	return u.list(ctx, filter, page, pageSize)
+}
+
+func (u *AuditUsecase) list(ctx context.Context, filter dto.AuditFilter, page, pageSize int) ([]dto.AuditEntryResponse, int64, error) {
+	offset := (page - 1) * pageSize
+
+	entries, total, err := u.repo.List(ctx, filter, offset, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]dto.AuditEntryResponse, len(entries))
+	for i, entry := range entries {
+		result[i] = dto.AuditFromModel(&entry)
+	}
+
+	return result, total, nil
+}
+
+// parseAuditTime принимает RFC 3339 или дату YYYY-MM-DD;
+// дата как верхняя граница включает весь день.
+func parseAuditTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
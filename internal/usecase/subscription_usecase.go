@@ -1,72 +1,275 @@
 package usecase
 
 import (
+	"context"
+	"errors"
 	"time"
 
 	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/events"
 	"github.com/BabichevDima/subManager/internal/models"
 	"github.com/BabichevDima/subManager/internal/repository"
+	"github.com/BabichevDima/subManager/internal/tracing"
+	"github.com/BabichevDima/subManager/pkg/logger"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 )
 
 type SubscriptionUsecase struct {
-	repo *repository.SubscriptionRepository
+	repo   *repository.SubscriptionRepository
+	events *events.Bus
+
+	// pending копит события изменений внутри транзакции: они публикуются
+	// только после её фиксации
+	pending *[]dto.SubscriptionEvent
 }
 
-func NewSubscriptionUsecase(r *repository.SubscriptionRepository) *SubscriptionUsecase {
-	return &SubscriptionUsecase{repo: r}
+func NewSubscriptionUsecase(r *repository.SubscriptionRepository, bus *events.Bus) *SubscriptionUsecase {
+	return &SubscriptionUsecase{repo: r, events: bus}
 }
 
-func (u *SubscriptionUsecase) Subscribe(request dto.RequestSubscription) (dto.ResponseSubscription, error) {
-	startDate, err := time.Parse("01-2006", request.StartDate)
+func (u *SubscriptionUsecase) Subscribe(ctx context.Context, request dto.RequestSubscription) (_ dto.ResponseSubscription, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.Subscribe")
+	defer tracing.End(span, &err)
+
+	resp, err := newSubscription(request)
+	if err != nil {
+		return dto.ResponseSubscription{}, err
+	}
+
+	exists, err := u.repo.Exists(ctx, resp.ServiceName, resp.UserID)
 	if err != nil {
 		return dto.ResponseSubscription{}, err
 	}
+	if exists {
+		return dto.ResponseSubscription{}, dto.ErrSubscriptionExists
+	}
+
+	if err := u.repo.Create(ctx, resp); err != nil {
+		return dto.ResponseSubscription{}, err
+	}
+
+	created := dto.ResponseSubscription{
+		ID:          resp.ID,
+		ServiceName: resp.ServiceName,
+		Price:       resp.Price,
+		UserID:      resp.UserID,
+		StartDate:   resp.StartDate.Format("01-2006"),
+		EndDate:     formatTimePtr(resp.EndDate),
+		Version:     resp.Version,
+		CreatedAt:   resp.CreatedAt,
+		UpdatedAt:   resp.UpdatedAt,
+	}
+	u.publish(dto.EventSubscriptionCreated, created)
+
+	return created, nil
+}
+
+// newSubscription разбирает полное описание подписки из запроса
+func newSubscription(request dto.RequestSubscription) (*models.Subscription, error) {
+	startDate, err := time.Parse("01-2006", request.StartDate)
+	if err != nil {
+		return nil, dto.InvalidField("start_date", "must be in MM-YYYY format")
+	}
 
 	var endDate *time.Time
 	if request.EndDate != "" {
 		parsedEndDate, err := time.Parse("01-2006", request.EndDate)
 		if err != nil {
-			return dto.ResponseSubscription{}, err
+			return nil, dto.InvalidField("end_date", "must be in MM-YYYY format")
 		}
 		endDate = &parsedEndDate
 	}
 
 	userUUID, err := uuid.Parse(request.UserID)
 	if err != nil {
-		return dto.ResponseSubscription{}, err
-	}
-
-	exists, err := u.repo.Exists(request.ServiceName, userUUID)
-	if err != nil {
-		return dto.ResponseSubscription{}, err
-	}
-	if exists {
-		return dto.ResponseSubscription{}, dto.ErrSubscriptionExists
+		return nil, dto.InvalidField("user_id", "must be a valid UUID")
 	}
 
-	resp := &models.Subscription{
+	return &models.Subscription{
 		ServiceName: request.ServiceName,
 		Price:       request.Price,
 		UserID:      userUUID,
 		StartDate:   startDate,
 		EndDate:     endDate,
+		Version:     1,
+	}, nil
+}
+
+// ImportSubscriptions создаёт подписки из уже провалидированных строк в одной транзакции.
+// Дубликаты и строки с некорректными значениями пропускаются и попадают в отчёт;
+// в режиме dryRun транзакция откатывается.
+func (u *SubscriptionUsecase) ImportSubscriptions(ctx context.Context, rows []dto.ImportRow, dryRun bool) (_ dto.ImportReport, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.ImportSubscriptions")
+	defer tracing.End(span, &err)
+
+	report := dto.ImportReport{
+		DryRun: dryRun,
+		Rows:   make([]dto.ImportRowResult, 0, len(rows)),
 	}
 
-	if err := u.repo.Create(resp); err != nil {
-		return dto.ResponseSubscription{}, err
+	err = u.inTx(ctx, func(tx *SubscriptionUsecase) error {
+		for _, row := range rows {
+			result := dto.ImportRowResult{Line: row.Line}
+
+			if row.Error != "" {
+				result.Status = dto.ImportStatusInvalid
+				result.Error = row.Error
+				report.Add(result)
+				continue
+			}
+
+			created, err := tx.Subscribe(ctx, row.Request)
+			switch {
+			case err == nil:
+				result.Status = dto.ImportStatusCreated
+				if !dryRun {
+					result.ID = &created.ID
+				}
+			case errors.Is(err, dto.ErrSubscriptionExists):
+				result.Status = dto.ImportStatusDuplicate
+				result.Error = err.Error()
+			case errors.Is(err, dto.ErrValidation):
+				result.Status = dto.ImportStatusInvalid
+				result.Error = err.Error()
+			default:
+				return err
+			}
+			report.Add(result)
+		}
+
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return dto.ImportReport{}, err
 	}
 
-	return dto.ResponseSubscription{
-		ID:          resp.ID,
-		ServiceName: resp.ServiceName,
-		Price:       resp.Price,
-		UserID:      resp.UserID,
-		StartDate:   resp.StartDate.Format("01-2006"),
-		EndDate:     formatTimePtr(resp.EndDate),
-		CreatedAt:   resp.CreatedAt,
-		UpdatedAt:   resp.UpdatedAt,
-	}, nil
+	logger.FromContext(ctx).Info("subscriptions imported",
+		zap.Bool("dry_run", dryRun),
+		zap.Int("created", report.Created),
+		zap.Int("duplicates", report.Duplicates),
+		zap.Int("invalid", report.Invalid),
+	)
+	return report, nil
+}
+
+var errDryRun = errors.New("dry run")
+
+// ExecuteBatch выполняет операции пакета по порядку. В атомарном режиме все операции
+// выполняются в одной транзакции: первая же ошибка откатывает остальные, и они получают
+// dto.ErrBatchAborted. Иначе каждая операция применяется независимо.
+func (u *SubscriptionUsecase) ExecuteBatch(ctx context.Context, items []dto.BatchItem, atomic bool) (_ []dto.BatchOutcome, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.ExecuteBatch")
+	defer tracing.End(span, &err)
+
+	outcomes := make([]dto.BatchOutcome, len(items))
+
+	if !atomic {
+		for i, item := range items {
+			outcomes[i] = u.executeBatchItem(ctx, item)
+		}
+		return outcomes, nil
+	}
+
+	failed := -1
+	for i, item := range items {
+		if item.Invalid != nil {
+			outcomes[i] = invalidBatchItem(item)
+			failed = i
+			break
+		}
+	}
+
+	if failed < 0 {
+		err := u.inTx(ctx, func(tx *SubscriptionUsecase) error {
+			for i, item := range items {
+				outcomes[i] = tx.executeBatchItem(ctx, item)
+				if outcomes[i].Err != nil {
+					failed = i
+					return dto.ErrBatchAborted
+				}
+			}
+			return nil
+		})
+		if err != nil && failed < 0 {
+			return nil, err
+		}
+	}
+
+	if failed >= 0 {
+		logger.FromContext(ctx).Info("atomic batch rolled back", zap.Int("failed_index", failed))
+		for i := range outcomes {
+			if i != failed {
+				outcomes[i] = dto.BatchOutcome{Err: dto.ErrBatchAborted}
+			}
+		}
+	}
+
+	return outcomes, nil
+}
+
+func (u *SubscriptionUsecase) executeBatchItem(ctx context.Context, item dto.BatchItem) dto.BatchOutcome {
+	if item.Invalid != nil {
+		return invalidBatchItem(item)
+	}
+
+	var (
+		subscription dto.ResponseSubscription
+		err          error
+	)
+	switch item.Op {
+	case dto.BatchOpCreate:
+		subscription, err = u.Subscribe(ctx, item.Create)
+	case dto.BatchOpUpdate:
+		subscription, err = u.UpdateSubscription(ctx, item.ID, item.Update, item.IfMatch)
+	case dto.BatchOpDelete:
+		return dto.BatchOutcome{Err: u.DeleteSubscription(ctx, item.ID, item.IfMatch)}
+	}
+	if err != nil {
+		return dto.BatchOutcome{Err: err}
+	}
+
+	return dto.BatchOutcome{Subscription: &subscription}
+}
+
+func invalidBatchItem(item dto.BatchItem) dto.BatchOutcome {
+	return dto.BatchOutcome{Err: item.Invalid}
+}
+
+// inTx выполняет fn с копией usecase, работающей внутри одной транзакции.
+// События изменений публикуются, только если транзакция зафиксирована.
+func (u *SubscriptionUsecase) inTx(ctx context.Context, fn func(tx *SubscriptionUsecase) error) error {
+	var pending []dto.SubscriptionEvent
+	err := u.repo.Transaction(ctx, func(repo *repository.SubscriptionRepository) error {
+		return fn(&SubscriptionUsecase{repo: repo, events: u.events, pending: &pending})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, event := range pending {
+		u.events.Publish(event)
+	}
+	return nil
+}
+
+// publish отправляет событие об изменении подписки в шину
+func (u *SubscriptionUsecase) publish(eventType string, subscription dto.ResponseSubscription) {
+	event := dto.SubscriptionEvent{
+		ID:           uuid.New(),
+		Type:         eventType,
+		Subscription: subscription,
+		OccurredAt:   time.Now(),
+	}
+
+	if u.pending != nil {
+		*u.pending = append(*u.pending, event)
+		return
+	}
+	u.events.Publish(event)
 }
 
 func formatTimePtr(t *time.Time) *string {
@@ -77,36 +280,43 @@ func formatTimePtr(t *time.Time) *string {
 	return &formatted
 }
 
-func (u *SubscriptionUsecase) GetSubscriptionByID(id string) (dto.ResponseSubscription, error) {
+func (u *SubscriptionUsecase) GetSubscriptionByID(ctx context.Context, id string, includeDeleted bool) (_ dto.ResponseSubscription, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.GetSubscriptionByID")
+	defer tracing.End(span, &err)
+
 	subscriptionId, err := uuid.Parse(id)
 	if err != nil {
 		return dto.ResponseSubscription{}, dto.ErrInvalidID
 	}
 
-	subscriptionResp, err := u.repo.GetByID(subscriptionId)
+	subscriptionResp, err := u.repo.GetByID(ctx, subscriptionId, includeDeleted)
 	if err != nil {
 		return dto.ResponseSubscription{}, err
 	}
 
-	return dto.ResponseSubscription{
-		ID:          subscriptionResp.ID,
-		ServiceName: subscriptionResp.ServiceName,
-		Price:       subscriptionResp.Price,
-		UserID:      subscriptionResp.UserID,
-		StartDate:   subscriptionResp.StartDate.Format("01-2006"),
-		EndDate:     formatTimePtr(subscriptionResp.EndDate),
-		CreatedAt:   subscriptionResp.CreatedAt,
-		UpdatedAt:   subscriptionResp.UpdatedAt,
-	}, nil
+	return dto.FromModel(subscriptionResp), nil
 }
 
-func (u *SubscriptionUsecase) GetAllSubscriptions(page, pageSize int) ([]dto.ResponseSubscription, int64, error) {
-	var subscriptions []models.Subscription
-	var total int64
+func (u *SubscriptionUsecase) GetAllSubscriptions(ctx context.Context, req dto.SubscriptionListRequest, page, pageSize int) (_ []dto.ResponseSubscription, _ int64, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.GetAllSubscriptions")
+	defer tracing.End(span, &err)
+
+	return u.ListSubscriptions(ctx, req, dto.SubscriptionSort{}, (page-1)*pageSize, pageSize)
+}
 
-	offset := (page - 1) * pageSize
+// ListSubscriptions возвращает до limit подписок по фильтрам, начиная с offset, в порядке sort,
+// и общее число подходящих подписок
+func (u *SubscriptionUsecase) ListSubscriptions(ctx context.Context, req dto.SubscriptionListRequest, sort dto.SubscriptionSort, offset, limit int) (_ []dto.ResponseSubscription, _ int64, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.ListSubscriptions")
+	defer tracing.End(span, &err)
 
-	subscriptions, total, err := u.repo.GetAll(offset, pageSize)
+	filter, err := listFilter(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	filter.Sort = sort
+
+	subscriptions, total, err := u.repo.GetAll(ctx, filter, offset, limit)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -119,81 +329,293 @@ func (u *SubscriptionUsecase) GetAllSubscriptions(page, pageSize int) ([]dto.Res
 	return result, total, nil
 }
 
-func (u *SubscriptionUsecase) DeleteSubscription(id string) error {
+// ExportSubscriptions передаёт в fn подписки по фильтрам списка по одной, без пагинации.
+// Ошибки разбора фильтров возвращаются до первого вызова fn.
+func (u *SubscriptionUsecase) ExportSubscriptions(ctx context.Context, req dto.SubscriptionListRequest, fn func(sub dto.ResponseSubscription) error) (err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.ExportSubscriptions")
+	defer tracing.End(span, &err)
+
+	filter, err := listFilter(req)
+	if err != nil {
+		return err
+	}
+
+	return u.repo.Iterate(ctx, filter, func(sub *models.Subscription) error {
+		return fn(dto.FromModel(sub))
+	})
+}
+
+// ExportTotalCost передаёт в fn подписки, из которых складывается стоимость за период
+func (u *SubscriptionUsecase) ExportTotalCost(ctx context.Context, req dto.TotalCostRequest, fn func(sub dto.ResponseSubscription) error) (err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.ExportTotalCost")
+	defer tracing.End(span, &err)
+
+	filter, err := totalCostFilter(req)
+	if err != nil {
+		return err
+	}
+
+	return u.repo.IterateTotalCost(ctx, filter, func(sub *models.Subscription) error {
+		return fn(dto.FromModel(sub))
+	})
+}
+
+// GetUserRenewals возвращает подписки пользователя для календаря продлений:
+// удалённые и завершившиеся до текущего месяца не включаются
+func (u *SubscriptionUsecase) GetUserRenewals(ctx context.Context, userID string) (_ []dto.ResponseSubscription, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.GetUserRenewals")
+	defer tracing.End(span, &err)
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, dto.ErrInvalidID
+	}
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var result []dto.ResponseSubscription
+	err = u.repo.Iterate(ctx, dto.SubscriptionFilter{UserID: &userUUID}, func(sub *models.Subscription) error {
+		if sub.EndDate != nil && sub.EndDate.Before(monthStart) {
+			return nil
+		}
+		result = append(result, dto.FromModel(sub))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// GetUsersSubscriptions возвращает подписки нескольких пользователей одним запросом,
+// сгруппированные по пользователю
+func (u *SubscriptionUsecase) GetUsersSubscriptions(ctx context.Context, userIDs []string, includeDeleted bool) (_ map[string][]dto.ResponseSubscription, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.GetUsersSubscriptions")
+	defer tracing.End(span, &err)
+
+	ids, err := parseUserIDs(userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions, err := u.repo.GetByUserIDs(ctx, ids, includeDeleted)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]dto.ResponseSubscription, len(userIDs))
+	for i := range subscriptions {
+		userID := subscriptions[i].UserID.String()
+		result[userID] = append(result[userID], dto.FromModel(&subscriptions[i]))
+	}
+	return result, nil
+}
+
+func parseUserIDs(userIDs []string) ([]uuid.UUID, error) {
+	ids := make([]uuid.UUID, len(userIDs))
+	for i, userID := range userIDs {
+		id, err := uuid.Parse(userID)
+		if err != nil {
+			return nil, dto.InvalidField("user_id", "must be a valid UUID")
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func listFilter(req dto.SubscriptionListRequest) (dto.SubscriptionFilter, error) {
+	filter := dto.SubscriptionFilter{
+		ServiceName:    req.ServiceName,
+		IncludeDeleted: req.IncludeDeleted,
+	}
+
+	if req.UserID != "" {
+		userUUID, err := uuid.Parse(req.UserID)
+		if err != nil {
+			return dto.SubscriptionFilter{}, dto.InvalidField("user_id", "must be a valid UUID")
+		}
+		filter.UserID = &userUUID
+	}
+
+	return filter, nil
+}
+
+func (u *SubscriptionUsecase) DeleteSubscription(ctx context.Context, id string, ifMatch dto.IfMatch) (err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.DeleteSubscription")
+	defer tracing.End(span, &err)
+
 	subscriptionId, err := uuid.Parse(id)
 	if err != nil {
 		return dto.ErrInvalidID
 	}
 
-	return u.repo.Delete(subscriptionId)
+	deleted, err := u.repo.Delete(ctx, subscriptionId, ifMatch)
+	if err != nil {
+		return err
+	}
+
+	u.publish(dto.EventSubscriptionDeleted, dto.FromModel(deleted))
+	return nil
 }
 
-func (u *SubscriptionUsecase) UpdateSubscription(id string, req dto.UpdateSubscriptionRequest) (dto.ResponseSubscription, error) {
+func (u *SubscriptionUsecase) RestoreSubscription(ctx context.Context, id string) (_ dto.ResponseSubscription, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.RestoreSubscription")
+	defer tracing.End(span, &err)
+
 	subscriptionID, err := uuid.Parse(id)
 	if err != nil {
 		return dto.ResponseSubscription{}, dto.ErrInvalidID
 	}
 
-	existing, err := u.repo.GetByID(subscriptionID)
+	deleted, err := u.repo.GetByID(ctx, subscriptionID, true)
 	if err != nil {
-		return dto.ResponseSubscription{}, dto.ErrRecordNotFound
+		return dto.ResponseSubscription{}, err
+	}
+	if !deleted.DeletedAt.Valid {
+		return dto.ResponseSubscription{}, dto.ErrNotDeleted
 	}
 
-	if req.ServiceName != "" {
-		existing.ServiceName = req.ServiceName
+	// дубликат проверяется в транзакции восстановления
+	restored, err := u.repo.Restore(ctx, subscriptionID)
+	if err != nil {
+		return dto.ResponseSubscription{}, err
 	}
 
-	if req.Price > 0 {
-		existing.Price = req.Price
+	response := dto.FromModel(restored)
+	u.publish(dto.EventSubscriptionRestored, response)
+	return response, nil
+}
+
+// UpdateSubscription применяет к подписке патч в формате JSON Merge Patch:
+// отсутствующие поля не меняются, end_date: null снимает дату окончания
+func (u *SubscriptionUsecase) UpdateSubscription(ctx context.Context, id string, req dto.UpdateSubscriptionRequest, ifMatch dto.IfMatch) (_ dto.ResponseSubscription, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.UpdateSubscription")
+	defer tracing.End(span, &err)
+
+	existing, err := u.getForUpdate(ctx, id, ifMatch)
+	if err != nil {
+		return dto.ResponseSubscription{}, err
 	}
 
-	var endDate *time.Time
-	if req.EndDate != "" {
-		parsedEndDate, err := time.Parse("01-2006", req.EndDate)
+	if req.ServiceName.Set {
+		existing.ServiceName = req.ServiceName.Value
+	}
+
+	if req.Price.Set {
+		existing.Price = req.Price.Value
+	}
+
+	if req.UserID.Set {
+		userID, err := uuid.Parse(req.UserID.Value)
 		if err != nil {
-			return dto.ResponseSubscription{}, dto.ErrInvalidFormat
+			return dto.ResponseSubscription{}, dto.InvalidField("user_id", "must be a valid UUID")
 		}
-		endDate = &parsedEndDate
+		existing.UserID = userID
+	}
+
+	if req.StartDate.Set {
+		startDate, err := time.Parse("01-2006", req.StartDate.Value)
+		if err != nil {
+			return dto.ResponseSubscription{}, dto.InvalidField("start_date", "must be in MM-YYYY format")
+		}
+		existing.StartDate = startDate
 	}
-	existing.EndDate = endDate
 
-	if err := u.repo.Update(existing); err != nil {
+	switch {
+	case req.EndDate.Null:
+		existing.EndDate = nil
+	case req.EndDate.Set:
+		endDate, err := time.Parse("01-2006", req.EndDate.Value)
+		if err != nil {
+			return dto.ResponseSubscription{}, dto.InvalidField("end_date", "must be in MM-YYYY format")
+		}
+		existing.EndDate = &endDate
+	}
+
+	return u.save(ctx, existing)
+}
+
+// ReplaceSubscription полностью заменяет данные подписки
+func (u *SubscriptionUsecase) ReplaceSubscription(ctx context.Context, id string, req dto.RequestSubscription, ifMatch dto.IfMatch) (_ dto.ResponseSubscription, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.ReplaceSubscription")
+	defer tracing.End(span, &err)
+
+	existing, err := u.getForUpdate(ctx, id, ifMatch)
+	if err != nil {
 		return dto.ResponseSubscription{}, err
 	}
 
-	return dto.ResponseSubscription{
-		ID:          existing.ID,
-		ServiceName: existing.ServiceName,
-		Price:       existing.Price,
-		UserID:      existing.UserID,
-		StartDate:   existing.StartDate.Format("01-2006"),
-		EndDate:     formatTimePtr(existing.EndDate),
-		CreatedAt:   existing.CreatedAt,
-		UpdatedAt:   existing.UpdatedAt,
-	}, nil
+	replacement, err := newSubscription(req)
+	if err != nil {
+		return dto.ResponseSubscription{}, err
+	}
+
+	existing.ServiceName = replacement.ServiceName
+	existing.Price = replacement.Price
+	existing.UserID = replacement.UserID
+	existing.StartDate = replacement.StartDate
+	existing.EndDate = replacement.EndDate
+
+	return u.save(ctx, existing)
 }
 
-func (u *SubscriptionUsecase) CalculateTotalCost(req dto.TotalCostRequest) (dto.TotalCostResponse, error) {
-	userUUID, err := uuid.Parse(req.UserID)
+// getForUpdate загружает подписку и проверяет её версию по ifMatch. Сама запись
+// в Update дополнительно условна по версии, так что гонка между чтением и записью
+// тоже даёт dto.ErrPreconditionFailed.
+func (u *SubscriptionUsecase) getForUpdate(ctx context.Context, id string, ifMatch dto.IfMatch) (*models.Subscription, error) {
+	subscriptionID, err := uuid.Parse(id)
 	if err != nil {
-		return dto.TotalCostResponse{}, dto.ErrInvalidID
+		return nil, dto.ErrInvalidID
 	}
 
-	startDate, err := time.Parse("01-2006", req.StartDate)
+	existing, err := u.repo.GetByID(ctx, subscriptionID, false)
 	if err != nil {
-		return dto.TotalCostResponse{}, err
+		return nil, err
 	}
 
-	endDate, err := time.Parse("01-2006", req.EndDate)
+	if !ifMatch.Allows(existing.Version) {
+		return nil, dto.ErrPreconditionFailed
+	}
+
+	return existing, nil
+}
+
+// save сохраняет изменённую подписку. Порядок дат проверяется здесь, а не в валидации
+// запроса, потому что патч может менять только одну из них.
+func (u *SubscriptionUsecase) save(ctx context.Context, subscription *models.Subscription) (dto.ResponseSubscription, error) {
+	if subscription.EndDate != nil && subscription.EndDate.Before(subscription.StartDate) {
+		return dto.ResponseSubscription{}, dto.InvalidField("end_date", "must not be before start_date")
+	}
+
+	exists, err := u.repo.ExistsExcept(ctx, subscription.ServiceName, subscription.UserID, subscription.ID)
 	if err != nil {
-		return dto.TotalCostResponse{}, err
+		return dto.ResponseSubscription{}, err
+	}
+	if exists {
+		return dto.ResponseSubscription{}, dto.ErrSubscriptionExists
 	}
 
-	if endDate.Before(startDate) {
+	if err := u.repo.Update(ctx, subscription); err != nil {
+		return dto.ResponseSubscription{}, err
+	}
+
+	response := dto.FromModel(subscription)
+	u.publish(dto.EventSubscriptionUpdated, response)
+	return response, nil
+}
+
+func (u *SubscriptionUsecase) CalculateTotalCost(ctx context.Context, req dto.TotalCostRequest) (_ dto.TotalCostResponse, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.CalculateTotalCost")
+	defer tracing.End(span, &err)
+
+	filter, err := totalCostFilter(req)
+	if err != nil {
 		return dto.TotalCostResponse{}, err
 	}
 
-	total, count, err := u.repo.CalculateTotalCost(userUUID, req.ServiceName, startDate, endDate)
+	total, count, err := u.repo.CalculateTotalCost(ctx, filter)
 	if err != nil {
 		return dto.TotalCostResponse{}, err
 	}
@@ -203,3 +625,65 @@ func (u *SubscriptionUsecase) CalculateTotalCost(req dto.TotalCostRequest) (dto.
 		SubscriptionsCount: count,
 	}, nil
 }
+
+// CalculateUsersTotalCost считает стоимость подписок каждого из пользователей за один период
+// одним запросом. Пользователи без подписок получают нулевую стоимость.
+func (u *SubscriptionUsecase) CalculateUsersTotalCost(ctx context.Context, req dto.UsersTotalCostRequest) (_ map[string]dto.TotalCostResponse, err error) {
+	ctx, span := tracing.Start(ctx, "SubscriptionUsecase.CalculateUsersTotalCost")
+	defer tracing.End(span, &err)
+
+	filter, err := costFilter(req)
+	if err != nil {
+		return nil, err
+	}
+
+	totals, err := u.repo.CalculateTotalCostByUser(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]dto.TotalCostResponse, len(filter.UserIDs))
+	for _, userID := range filter.UserIDs {
+		result[userID.String()] = totals[userID]
+	}
+	return result, nil
+}
+
+func totalCostFilter(req dto.TotalCostRequest) (dto.TotalCostFilter, error) {
+	return costFilter(dto.UsersTotalCostRequest{
+		UserIDs:     []string{req.UserID},
+		ServiceName: req.ServiceName,
+		StartDate:   req.StartDate,
+		EndDate:     req.EndDate,
+	})
+}
+
+func costFilter(req dto.UsersTotalCostRequest) (dto.TotalCostFilter, error) {
+	var errs dto.ValidationError
+
+	userIDs, err := parseUserIDs(req.UserIDs)
+	if err != nil {
+		errs.Add("user_id", "must be a valid UUID")
+	}
+
+	startDate, err := time.Parse("01-2006", req.StartDate)
+	if err != nil {
+		errs.Add("start_date", "must be in MM-YYYY format")
+	}
+
+	endDate, err := time.Parse("01-2006", req.EndDate)
+	if err != nil {
+		errs.Add("end_date", "must be in MM-YYYY format")
+	}
+
+	if err := errs.Err(); err != nil {
+		return dto.TotalCostFilter{}, err
+	}
+
+	return dto.TotalCostFilter{
+		UserIDs:     userIDs,
+		ServiceName: req.ServiceName,
+		StartDate:   startDate,
+		EndDate:     endDate,
+	}, nil
+}
@@ -0,0 +1,206 @@
+// Package validation проверяет DTO запросов по тегам validate.
+//
+// Правила в теге перечисляются через запятую:
+//
+//	required     поле не пустое
+//	nonempty     переданное поле патча не пустое
+//	notnull      поле патча нельзя очистить значением null
+//	gt=N         число больше N
+//	max=N        строка не длиннее N символов
+//	uuid         строка — UUID
+//	mmyyyy       строка — дата в формате MM-YYYY
+//	timestamp    строка — время в RFC 3339 или дата YYYY-MM-DD
+//	oneof=a b    строка — одно из перечисленных значений
+//	gtefield=F   дата MM-YYYY не раньше даты в поле F той же структуры
+//
+// Правила формата строк не применяются к пустым строкам: обязательность задаётся
+// только правилом required. На каждое поле сообщается первая нарушенная проверка.
+package validation
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/google/uuid"
+)
+
+const (
+	tagName     = "validate"
+	monthLayout = "01-2006"
+	dayLayout   = "2006-01-02"
+	nameTag     = "json"
+	rulesSep    = ","
+	paramSep    = "="
+	oneOfSep    = " "
+)
+
+// Optional поле патча, которое может отсутствовать или быть явным null
+type Optional interface {
+	OptionalValue() (value interface{}, set, null bool)
+}
+
+// Checker проверки уровня всей структуры, которые не выражаются тегами полей
+type Checker interface {
+	Check(errs *dto.ValidationError)
+}
+
+// Struct проверяет поля структуры v по тегам validate и возвращает *dto.ValidationError
+// со всеми найденными ошибками или nil
+func Struct(v interface{}) error {
+	var errs dto.ValidationError
+
+	parent := reflect.Indirect(reflect.ValueOf(v))
+	for i := 0; i < parent.NumField(); i++ {
+		field := parent.Type().Field(i)
+		tag := field.Tag.Get(tagName)
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+
+		if msg := checkField(parent, parent.Field(i), strings.Split(tag, rulesSep)); msg != "" {
+			errs.Add(fieldName(field), msg)
+		}
+	}
+
+	if checker, ok := v.(Checker); ok {
+		checker.Check(&errs)
+	}
+
+	return errs.Err()
+}
+
+func checkField(parent, value reflect.Value, rules []string) string {
+	if optional, ok := value.Interface().(Optional); ok {
+		inner, set, null := optional.OptionalValue()
+		switch {
+		case !set:
+			return ""
+		case null:
+			if hasRule(rules, "notnull") {
+				return "cannot be cleared"
+			}
+			return ""
+		}
+		value = reflect.ValueOf(inner)
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, paramSep)
+		if msg := checkRule(parent, value, name, param); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+func checkRule(parent, value reflect.Value, rule, param string) string {
+	switch rule {
+	case "required", "nonempty":
+		if !value.IsZero() {
+			return ""
+		}
+		if rule == "nonempty" {
+			return "must not be empty"
+		}
+		return "is required"
+	}
+
+	if value.Kind() == reflect.Int || value.Kind() == reflect.Int64 {
+		if rule == "gt" {
+			limit, _ := strconv.ParseInt(param, 10, 64)
+			if value.Int() <= limit {
+				return "must be greater than " + param
+			}
+		}
+		return ""
+	}
+
+	s := value.String()
+	if value.Kind() != reflect.String || s == "" {
+		return ""
+	}
+
+	switch rule {
+	case "max":
+		limit, _ := strconv.Atoi(param)
+		if utf8.RuneCountInString(s) > limit {
+			return "must be at most " + param + " characters"
+		}
+	case "uuid":
+		if _, err := uuid.Parse(s); err != nil {
+			return "must be a valid UUID"
+		}
+	case "mmyyyy":
+		if _, err := time.Parse(monthLayout, s); err != nil {
+			return "must be in MM-YYYY format"
+		}
+	case "timestamp":
+		if _, err := time.Parse(time.RFC3339, s); err == nil {
+			return ""
+		}
+		if _, err := time.Parse(dayLayout, s); err != nil {
+			return "must be RFC 3339 or YYYY-MM-DD"
+		}
+	case "oneof":
+		options := strings.Split(param, oneOfSep)
+		for _, option := range options {
+			if s == option {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(options, ", ")
+	case "gtefield":
+		return checkNotBefore(parent, s, param)
+	}
+	return ""
+}
+
+// checkNotBefore сравнивает даты MM-YYYY; некорректные даты отмечают их собственные правила
+func checkNotBefore(parent reflect.Value, value, otherName string) string {
+	other, ok := parent.Type().FieldByName(otherName)
+	if !ok {
+		return ""
+	}
+
+	otherValue := parent.FieldByIndex(other.Index).Interface()
+	if optional, ok := otherValue.(Optional); ok {
+		otherValue, _, _ = optional.OptionalValue()
+	}
+	otherString, _ := otherValue.(string)
+
+	date, err := time.Parse(monthLayout, value)
+	if err != nil {
+		return ""
+	}
+	otherDate, err := time.Parse(monthLayout, otherString)
+	if err != nil {
+		return ""
+	}
+
+	if date.Before(otherDate) {
+		return "must not be before " + fieldName(other)
+	}
+	return ""
+}
+
+func hasRule(rules []string, name string) bool {
+	for _, rule := range rules {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldName возвращает имя поля из тега json, как его видит клиент
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get(nameTag), rulesSep)
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
@@ -0,0 +1,231 @@
+// Package client — типизированный клиент HTTP API subManager.
+//
+// Клиент повторяет запросы с экспоненциальной задержкой при сетевых ошибках,
+// ответах 5xx и 429. POST-запросы получают заголовок Idempotency-Key, общий для
+// всех попыток, поэтому повтор не создаёт дубликатов. Ошибки API возвращаются
+// как *Error и сравниваются с переменными Err* через errors.Is.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	apiKeyHeader         = "X-API-Key"
+	idempotencyKeyHeader = "Idempotency-Key"
+
+	defaultRetries    = 3
+	defaultBackoff    = 200 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+)
+
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	apiKey     string
+	retries    int
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+type Option func(*Client)
+
+// WithHTTPClient задаёт HTTP-клиент, например с таймаутом или своим транспортом
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAPIKey передаёт ключ в заголовке X-API-Key
+func WithAPIKey(key string) Option {
+	return func(c *Client) {
+		c.apiKey = key
+	}
+}
+
+// WithRetries задаёт число повторов и начальную задержку между ними.
+// Задержка удваивается с каждой попыткой и не превышает maxBackoff.
+// retries = 0 отключает повторы.
+func WithRetries(retries int, backoff, maxBackoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+		c.maxBackoff = maxBackoff
+	}
+}
+
+// New создаёт клиент для сервиса с адресом baseURL, например http://localhost:8080
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme and host are required", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		retries:    defaultRetries,
+		backoff:    defaultBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// request описание одного вызова API; тело хранится целиком, чтобы его можно было
+// отправить повторно
+type request struct {
+	method      string
+	path        string
+	query       url.Values
+	header      http.Header
+	body        []byte
+	contentType string
+}
+
+func jsonRequest(method, path string, body interface{}) (request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return request{}, err
+	}
+	return request{method: method, path: path, body: data, contentType: "application/json"}, nil
+}
+
+// do выполняет запрос и декодирует JSON-ответ в out, если out не nil
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// send выполняет запрос с повторами и возвращает первый ответ без ошибки.
+// Тело ответа закрывает вызывающий.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	if req.method == http.MethodPost && req.header.Get(idempotencyKeyHeader) == "" {
+		if req.header == nil {
+			req.header = make(http.Header)
+		}
+		req.header.Set(idempotencyKeyHeader, uuid.NewString())
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, req)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			return resp, nil
+		}
+
+		var delay time.Duration
+		if err == nil {
+			delay = retryAfter(resp.Header.Get("Retry-After"))
+			err = readError(resp)
+		}
+
+		if attempt >= c.retries || !retryable(ctx, err) {
+			return nil, err
+		}
+
+		if delay <= 0 {
+			delay = c.delay(attempt)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, req request) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range req.header {
+		httpReq.Header[name] = values
+	}
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if c.apiKey != "" {
+		httpReq.Header.Set(apiKeyHeader, c.apiKey)
+	}
+
+	return c.httpClient.Do(httpReq)
+}
+
+// delay возвращает задержку перед повтором с номером attempt: экспонента с джиттером
+func (c *Client) delay(attempt int) time.Duration {
+	delay := c.backoff << attempt
+	if delay <= 0 || delay > c.maxBackoff {
+		delay = c.maxBackoff
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// retryable повторяет сетевые ошибки, 5xx и 429, но не отменённый контекст
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// retryAfter разбирает Retry-After в секундах или как HTTP-дату
+func retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
@@ -0,0 +1,431 @@
+package client_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	gosqlite "github.com/glebarez/go-sqlite"
+	"github.com/glebarez/sqlite"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/BabichevDima/subManager/internal/config"
+	"github.com/BabichevDima/subManager/internal/dto"
+	"github.com/BabichevDima/subManager/internal/events"
+	router "github.com/BabichevDima/subManager/internal/http"
+	"github.com/BabichevDima/subManager/internal/http/handlers"
+	"github.com/BabichevDima/subManager/internal/http/response"
+	"github.com/BabichevDima/subManager/internal/repository"
+	"github.com/BabichevDima/subManager/internal/usecase"
+	"github.com/BabichevDima/subManager/pkg/client"
+	"github.com/BabichevDima/subManager/pkg/logger"
+)
+
+// функции Postgres, на которые опираются схема и репозиторий. Блокировка не нужна:
+// SQLite в одном процессе и так выполняет записи по очереди
+var registerPostgresFuncs = sync.OnceFunc(func() {
+	gosqlite.MustRegisterScalarFunction("gen_random_uuid", 0, func(_ *gosqlite.FunctionContext, _ []driver.Value) (driver.Value, error) {
+		return uuid.NewString(), nil
+	})
+	gosqlite.MustRegisterScalarFunction("now", 0, func(_ *gosqlite.FunctionContext, _ []driver.Value) (driver.Value, error) {
+		return time.Now().UTC().Format("2006-01-02 15:04:05.999999999"), nil
+	})
+	gosqlite.MustRegisterScalarFunction("hashtextextended", 2, func(_ *gosqlite.FunctionContext, _ []driver.Value) (driver.Value, error) {
+		return int64(0), nil
+	})
+	gosqlite.MustRegisterScalarFunction("pg_advisory_xact_lock", 1, func(_ *gosqlite.FunctionContext, _ []driver.Value) (driver.Value, error) {
+		return nil, nil
+	})
+})
+
+// schema повторяет таблицы из миграций: AutoMigrate не подходит, потому что SQLite
+// требует скобки вокруг умолчаний вида now()
+var schema = []string{
+	`CREATE TABLE subscriptions (
+		id uuid PRIMARY KEY DEFAULT (gen_random_uuid()),
+		service_name varchar(100) NOT NULL,
+		price integer NOT NULL,
+		user_id uuid NOT NULL,
+		start_date date NOT NULL,
+		end_date date,
+		version bigint NOT NULL DEFAULT 1,
+		created_at timestamp NOT NULL DEFAULT (now()),
+		updated_at timestamp NOT NULL DEFAULT (now()),
+		deleted_at timestamp
+	)`,
+	`CREATE TABLE audit_log (
+		id uuid PRIMARY KEY DEFAULT (gen_random_uuid()),
+		subscription_id uuid NOT NULL,
+		actor varchar(100) NOT NULL,
+		action varchar(20) NOT NULL,
+		before jsonb,
+		after jsonb,
+		diff jsonb NOT NULL,
+		request_id varchar(100),
+		created_at timestamp NOT NULL DEFAULT (now())
+	)`,
+	`CREATE TABLE outbox (
+		id uuid PRIMARY KEY DEFAULT (gen_random_uuid()),
+		event_type varchar(100) NOT NULL,
+		aggregate_id uuid NOT NULL,
+		payload jsonb NOT NULL,
+		attempts integer NOT NULL DEFAULT 0,
+		last_error text,
+		created_at timestamp NOT NULL DEFAULT (now()),
+		sent_at timestamp,
+		next_attempt_at timestamp NOT NULL DEFAULT (now()),
+		failed_at timestamp
+	)`,
+}
+
+// newAPI поднимает сервер с настоящими маршрутами и обработчиками поверх SQLite.
+// wrap, если задан, оборачивает маршрутизатор, чтобы подменять или считать ответы.
+func newAPI(t *testing.T, wrap func(http.Handler) http.Handler) *httptest.Server {
+	t.Helper()
+	registerPostgresFuncs()
+	logger.L = zap.NewNop()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "api.db")+"?_pragma=busy_timeout(5000)"), &gorm.Config{
+		Logger: gormlogger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range schema {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bus := events.NewBus(100)
+	subscriptions := usecase.NewSubscriptionUsecase(repository.NewSubscriptionRepository(db), bus)
+	routes := router.Routes(
+		handlers.NewSubscriptionHandler(subscriptions, config.ConcurrencyConfig{}, 0),
+		handlers.NewAuditHandler(usecase.NewAuditUsecase(repository.NewAuditRepository(db))),
+		handlers.NewCalendarHandler(subscriptions, config.CalendarConfig{}),
+		handlers.NewEventsHandler(bus, config.EventsConfig{}),
+	)
+
+	mux := http.NewServeMux()
+	router.RegisterRoutes(mux, routes, router.Document(routes), false)
+
+	var handler http.Handler = mux
+	if wrap != nil {
+		handler = wrap(mux)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		server.Close()
+		bus.Close()
+	})
+	return server
+}
+
+func newClient(t *testing.T, server *httptest.Server, opts ...client.Option) *client.Client {
+	t.Helper()
+	c, err := client.New(server.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func subscriptionRequest(userID uuid.UUID, service string) client.SubscriptionRequest {
+	return client.SubscriptionRequest{
+		ServiceName: service,
+		Price:       400,
+		UserID:      userID.String(),
+		StartDate:   "07-2025",
+	}
+}
+
+func TestCRUD(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newAPI(t, nil))
+	userID := uuid.New()
+
+	created, err := c.CreateSubscription(ctx, subscriptionRequest(userID, "Yandex Plus"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if created.ID == uuid.Nil || created.ServiceName != "Yandex Plus" || created.UserID != userID || created.Version != 1 {
+		t.Fatalf("create returned %+v", created)
+	}
+
+	got, err := c.GetSubscription(ctx, created.ID, false)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if got.ID != created.ID || got.Price != 400 || got.StartDate != "07-2025" {
+		t.Fatalf("get returned %+v", got)
+	}
+
+	replacement := subscriptionRequest(userID, "Yandex Plus")
+	replacement.Price = 500
+	replacement.EndDate = "12-2025"
+	replaced, err := c.ReplaceSubscription(ctx, created.ID, replacement, created.Version)
+	if err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	if replaced.Price != 500 || replaced.EndDate == nil || *replaced.EndDate != "12-2025" || replaced.Version != 2 {
+		t.Fatalf("replace returned %+v", replaced)
+	}
+
+	patched, err := c.UpdateSubscription(ctx, created.ID, client.SubscriptionPatch{
+		Price:   client.Int(600),
+		EndDate: client.Null(),
+	}, replaced.Version)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if patched.Price != 600 || patched.EndDate != nil || patched.ServiceName != "Yandex Plus" || patched.Version != 3 {
+		t.Fatalf("update returned %+v", patched)
+	}
+
+	if err := c.DeleteSubscription(ctx, created.ID, patched.Version); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := c.GetSubscription(ctx, created.ID, false); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("get after delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestAllSubscriptionsPaginates(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	pages := 0
+	server := newAPI(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet && r.URL.Path == "/api/subscriptions" {
+				mu.Lock()
+				pages++
+				mu.Unlock()
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	c := newClient(t, server)
+
+	userID := uuid.New()
+	want := make(map[uuid.UUID]bool)
+	for _, service := range []string{"A", "B", "C", "D", "E", "F", "G"} {
+		sub, err := c.CreateSubscription(ctx, subscriptionRequest(userID, service))
+		if err != nil {
+			t.Fatalf("create %s: %v", service, err)
+		}
+		want[sub.ID] = true
+	}
+	// подписка другого пользователя не должна попасть в выборку
+	if _, err := c.CreateSubscription(ctx, subscriptionRequest(uuid.New(), "A")); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	seen := 0
+	for sub, err := range c.AllSubscriptions(ctx, client.ListFilter{UserID: userID.String()}, 3) {
+		if err != nil {
+			t.Fatalf("iterate: %v", err)
+		}
+		if !want[sub.ID] {
+			t.Fatalf("unexpected subscription %+v", sub)
+		}
+		delete(want, sub.ID)
+		seen++
+	}
+	if seen != 7 || len(want) != 0 {
+		t.Fatalf("iterated %d subscriptions, missing %d", seen, len(want))
+	}
+	if pages != 3 {
+		t.Fatalf("requested %d pages, want 3", pages)
+	}
+}
+
+func TestAllSubscriptionsStopsEarly(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newAPI(t, nil))
+
+	userID := uuid.New()
+	for _, service := range []string{"A", "B", "C"} {
+		if _, err := c.CreateSubscription(ctx, subscriptionRequest(userID, service)); err != nil {
+			t.Fatalf("create %s: %v", service, err)
+		}
+	}
+
+	seen := 0
+	for _, err := range c.AllSubscriptions(ctx, client.ListFilter{UserID: userID.String()}, 1) {
+		if err != nil {
+			t.Fatalf("iterate: %v", err)
+		}
+		seen++
+		if seen == 2 {
+			break
+		}
+	}
+	if seen != 2 {
+		t.Fatalf("iterated %d subscriptions, want 2", seen)
+	}
+}
+
+// flaky отвечает ошибками statuses на первые запросы, а затем передаёт их дальше;
+// attempts запоминает время и Idempotency-Key каждой попытки
+type flaky struct {
+	mu       sync.Mutex
+	statuses []int
+	attempts []attempt
+}
+
+type attempt struct {
+	at             time.Time
+	idempotencyKey string
+}
+
+func (f *flaky) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		n := len(f.attempts)
+		f.attempts = append(f.attempts, attempt{at: time.Now(), idempotencyKey: r.Header.Get("Idempotency-Key")})
+		f.mu.Unlock()
+
+		if n < len(f.statuses) {
+			switch f.statuses[n] {
+			case http.StatusTooManyRequests:
+				response.RespondWithError(w, r, dto.ErrRateLimited)
+			default:
+				response.RespondWithError(w, r, dto.ErrInternal)
+			}
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestRetriesServerErrorsWithBackoff(t *testing.T) {
+	const backoff = 40 * time.Millisecond
+
+	f := &flaky{statuses: []int{http.StatusInternalServerError, http.StatusTooManyRequests}}
+	c := newClient(t, newAPI(t, f.wrap), client.WithRetries(3, backoff, time.Second))
+
+	sub, err := c.CreateSubscription(context.Background(), subscriptionRequest(uuid.New(), "Netflix"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if sub.ID == uuid.Nil {
+		t.Fatalf("create returned %+v", sub)
+	}
+
+	if len(f.attempts) != 3 {
+		t.Fatalf("made %d attempts, want 3", len(f.attempts))
+	}
+	// задержка удваивается, джиттер оставляет от неё не меньше половины
+	for i := 1; i < len(f.attempts); i++ {
+		minDelay := (backoff << (i - 1)) / 2
+		if gap := f.attempts[i].at.Sub(f.attempts[i-1].at); gap < minDelay {
+			t.Errorf("attempt %d after %v, want at least %v", i+1, gap, minDelay)
+		}
+	}
+	// повтор POST идёт с тем же ключом, чтобы сервер не создал дубликат
+	key := f.attempts[0].idempotencyKey
+	if key == "" {
+		t.Fatal("POST sent without Idempotency-Key")
+	}
+	for i, a := range f.attempts {
+		if a.idempotencyKey != key {
+			t.Errorf("attempt %d used Idempotency-Key %q, want %q", i+1, a.idempotencyKey, key)
+		}
+	}
+}
+
+func TestRetriesGiveUp(t *testing.T) {
+	f := &flaky{statuses: []int{500, 500, 500}}
+	c := newClient(t, newAPI(t, f.wrap), client.WithRetries(2, time.Millisecond, 10*time.Millisecond))
+
+	_, err := c.GetSubscription(context.Background(), uuid.New(), false)
+	if !errors.Is(err, client.ErrInternal) {
+		t.Fatalf("got %v, want ErrInternal", err)
+	}
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("got %#v, want *client.Error with status 500", err)
+	}
+	if len(f.attempts) != 3 {
+		t.Fatalf("made %d attempts, want 3", len(f.attempts))
+	}
+}
+
+func TestClientErrorsAreNotRetried(t *testing.T) {
+	f := &flaky{}
+	c := newClient(t, newAPI(t, f.wrap), client.WithRetries(3, time.Millisecond, 10*time.Millisecond))
+
+	if _, err := c.GetSubscription(context.Background(), uuid.New(), false); !errors.Is(err, client.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	if len(f.attempts) != 1 {
+		t.Fatalf("made %d attempts, want 1", len(f.attempts))
+	}
+}
+
+func TestProblemErrors(t *testing.T) {
+	ctx := context.Background()
+	c := newClient(t, newAPI(t, nil), client.WithRetries(0, 0, 0))
+	userID := uuid.New()
+
+	created, err := c.CreateSubscription(ctx, subscriptionRequest(userID, "Spotify"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := c.GetSubscription(ctx, uuid.New(), false)
+		assertProblem(t, err, client.ErrNotFound, http.StatusNotFound)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		_, err := c.CreateSubscription(ctx, subscriptionRequest(userID, "Spotify"))
+		assertProblem(t, err, client.ErrSubscriptionExists, http.StatusConflict)
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		req := subscriptionRequest(userID, "Kinopoisk")
+		req.Price = -1
+		req.StartDate = "2025-07"
+		_, err := c.CreateSubscription(ctx, req)
+		apiErr := assertProblem(t, err, client.ErrValidation, http.StatusBadRequest)
+
+		fields := make(map[string]bool)
+		for _, field := range apiErr.Fields() {
+			fields[field.Field] = true
+		}
+		if !fields["price"] || !fields["start_date"] {
+			t.Fatalf("field errors %+v, want price and start_date", apiErr.Fields())
+		}
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		_, err := c.UpdateSubscription(ctx, created.ID, client.SubscriptionPatch{Price: client.Int(1)}, created.Version+1)
+		assertProblem(t, err, client.ErrPreconditionFailed, http.StatusPreconditionFailed)
+	})
+}
+
+func assertProblem(t *testing.T, err, target error, status int) *client.Error {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("got %v, want %v", err, target)
+	}
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %T, want *client.Error", err)
+	}
+	if apiErr.StatusCode != status || apiErr.Problem.Status != status {
+		t.Fatalf("got status %d (problem %d), want %d", apiErr.StatusCode, apiErr.Problem.Status, status)
+	}
+	return apiErr
+}
@@ -0,0 +1,86 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/BabichevDima/subManager/internal/dto"
+)
+
+// maxErrorBody ограничивает чтение тела ответа с ошибкой
+const maxErrorBody = 64 << 10
+
+// Ошибки API. Сравнивайте с ними через errors.Is: совпадение определяется по коду
+// проблемы из ответа, а не по тексту.
+var (
+	ErrSubscriptionExists   = dto.ErrSubscriptionExists
+	ErrInvalidID            = dto.ErrInvalidID
+	ErrNotFound             = dto.ErrRecordNotFound
+	ErrNotDeleted           = dto.ErrNotDeleted
+	ErrPreconditionFailed   = dto.ErrPreconditionFailed
+	ErrPreconditionRequired = dto.ErrPreconditionRequired
+	ErrValidation           = dto.ErrValidation
+	ErrBatchAborted         = dto.ErrBatchAborted
+	ErrInvalidBody          = dto.ErrInvalidBody
+	ErrBodyTooLarge         = dto.ErrBodyTooLarge
+	ErrUnsupportedMediaType = dto.ErrUnsupportedMediaType
+	ErrNotAcceptable        = dto.ErrNotAcceptable
+	ErrUnauthorized         = dto.ErrUnauthorized
+	ErrForbidden            = dto.ErrForbidden
+	ErrInvalidToken         = dto.ErrInvalidToken
+	ErrCalendarDisabled     = dto.ErrCalendarDisabled
+	ErrIdempotencyKeyReused = dto.ErrIdempotencyKeyReused
+	ErrIdempotencyKeyInUse  = dto.ErrIdempotencyKeyInUse
+	ErrInternal             = dto.ErrInternal
+)
+
+// Error ответ API с ошибкой. Problem разобран из application/problem+json;
+// для ответов другого типа (например, от прокси) заполнены только Status, Title и Detail.
+type Error struct {
+	StatusCode int
+	Problem    Problem
+}
+
+func (e *Error) Error() string {
+	msg := e.Problem.Title
+	if e.Problem.Detail != "" {
+		msg = e.Problem.Detail
+	}
+	if e.Problem.Code != "" {
+		return e.Problem.Code + ": " + msg
+	}
+	return http.StatusText(e.StatusCode) + ": " + msg
+}
+
+// Is сопоставляет ошибку с переменными Err* по коду проблемы
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*dto.Error)
+	return ok && e.Problem.Code != "" && t.Code == e.Problem.Code
+}
+
+// Fields возвращает ошибки полей из ответа validation_failed
+func (e *Error) Fields() []FieldError {
+	return e.Problem.Errors
+}
+
+// readError читает ответ с ошибкой и закрывает его тело
+func readError(resp *http.Response) error {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "application/problem+json" && json.Unmarshal(body, &apiErr.Problem) == nil {
+		return apiErr
+	}
+
+	apiErr.Problem = Problem{
+		Status: resp.StatusCode,
+		Title:  http.StatusText(resp.StatusCode),
+		Detail: strings.TrimSpace(string(body)),
+	}
+	return apiErr
+}
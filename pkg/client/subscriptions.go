@@ -0,0 +1,268 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+const subscriptionsPath = "/api/subscriptions"
+
+// ImportOptions параметры импорта CSV
+type ImportOptions struct {
+	// Delimiter разделитель столбцов; по умолчанию запятая
+	Delimiter string
+	// DateFormat формат дат из токенов YYYY, YY, MM, DD; по умолчанию MM-YYYY
+	DateFormat string
+	DryRun     bool
+}
+
+// CreateSubscription создаёт подписку
+func (c *Client) CreateSubscription(ctx context.Context, req SubscriptionRequest) (Subscription, error) {
+	var sub Subscription
+	r, err := jsonRequest(http.MethodPost, subscriptionsPath, req)
+	if err != nil {
+		return sub, err
+	}
+	return sub, c.do(ctx, r, &sub)
+}
+
+// GetSubscription возвращает подписку; includeDeleted доступен только администраторам
+func (c *Client) GetSubscription(ctx context.Context, id uuid.UUID, includeDeleted bool) (Subscription, error) {
+	var sub Subscription
+	r := request{method: http.MethodGet, path: subscriptionPath(id)}
+	if includeDeleted {
+		r.query = url.Values{"include_deleted": {"true"}}
+	}
+	return sub, c.do(ctx, r, &sub)
+}
+
+// ListSubscriptions возвращает страницу списка подписок
+func (c *Client) ListSubscriptions(ctx context.Context, filter ListFilter, page, pageSize int) (SubscriptionList, error) {
+	var list SubscriptionList
+	query := listQuery(filter)
+	setPage(query, page, pageSize)
+	return list, c.do(ctx, request{method: http.MethodGet, path: subscriptionsPath, query: query}, &list)
+}
+
+// AllSubscriptions обходит все подписки по фильтру, запрашивая страницы по мере
+// необходимости. Ошибка запроса возвращается последним элементом итерации.
+func (c *Client) AllSubscriptions(ctx context.Context, filter ListFilter, pageSize int) iter.Seq2[Subscription, error] {
+	return func(yield func(Subscription, error) bool) {
+		for page := 1; ; page++ {
+			list, err := c.ListSubscriptions(ctx, filter, page, pageSize)
+			if err != nil {
+				yield(Subscription{}, err)
+				return
+			}
+
+			for _, sub := range list.Data {
+				if !yield(sub, nil) {
+					return
+				}
+			}
+
+			if len(list.Data) == 0 || int64(page) >= list.Pagination.TotalPages {
+				return
+			}
+		}
+	}
+}
+
+// ReplaceSubscription полностью заменяет подписку. version — ожидаемая версия
+// (If-Match); 0 отправляет запрос без условия.
+func (c *Client) ReplaceSubscription(ctx context.Context, id uuid.UUID, req SubscriptionRequest, version int64) (Subscription, error) {
+	var sub Subscription
+	r, err := jsonRequest(http.MethodPut, subscriptionPath(id), req)
+	if err != nil {
+		return sub, err
+	}
+	r.header = ifMatch(version)
+	return sub, c.do(ctx, r, &sub)
+}
+
+// UpdateSubscription применяет патч к подписке. version — ожидаемая версия
+// (If-Match); 0 отправляет запрос без условия.
+func (c *Client) UpdateSubscription(ctx context.Context, id uuid.UUID, patch SubscriptionPatch, version int64) (Subscription, error) {
+	var sub Subscription
+	r, err := jsonRequest(http.MethodPatch, subscriptionPath(id), patch)
+	if err != nil {
+		return sub, err
+	}
+	r.contentType = "application/merge-patch+json"
+	r.header = ifMatch(version)
+	return sub, c.do(ctx, r, &sub)
+}
+
+// DeleteSubscription удаляет подписку. version — ожидаемая версия (If-Match);
+// 0 отправляет запрос без условия.
+func (c *Client) DeleteSubscription(ctx context.Context, id uuid.UUID, version int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: subscriptionPath(id), header: ifMatch(version)}, nil)
+}
+
+// RestoreSubscription восстанавливает удалённую подписку. Только для администраторов
+func (c *Client) RestoreSubscription(ctx context.Context, id uuid.UUID) (Subscription, error) {
+	var sub Subscription
+	return sub, c.do(ctx, request{method: http.MethodPost, path: subscriptionPath(id) + "/restore"}, &sub)
+}
+
+// Batch выполняет пакет операций. Ответ 207 с ошибками отдельных операций
+// не считается ошибкой: результаты каждой операции — в BatchResponse.Results.
+func (c *Client) Batch(ctx context.Context, ops []BatchOperation, atomic bool) (BatchResponse, error) {
+	var result BatchResponse
+	r, err := jsonRequest(http.MethodPost, subscriptionsPath+"/batch", ops)
+	if err != nil {
+		return result, err
+	}
+	r.query = url.Values{"atomic": {strconv.FormatBool(atomic)}}
+	return result, c.do(ctx, r, &result)
+}
+
+// BatchData кодирует данные операции пакета: SubscriptionRequest для create
+// или SubscriptionPatch для update
+func BatchData(v interface{}) (json.RawMessage, error) {
+	return json.Marshal(v)
+}
+
+// ImportSubscriptions импортирует подписки из CSV
+func (c *Client) ImportSubscriptions(ctx context.Context, csv io.Reader, opts ImportOptions) (ImportReport, error) {
+	var report ImportReport
+	body, err := io.ReadAll(csv)
+	if err != nil {
+		return report, err
+	}
+
+	query := url.Values{}
+	if opts.Delimiter != "" {
+		query.Set("delimiter", opts.Delimiter)
+	}
+	if opts.DateFormat != "" {
+		query.Set("date_format", opts.DateFormat)
+	}
+	if opts.DryRun {
+		query.Set("dry_run", "true")
+	}
+
+	r := request{method: http.MethodPost, path: subscriptionsPath + "/import", query: query, body: body, contentType: "text/csv"}
+	return report, c.do(ctx, r, &report)
+}
+
+// ExportSubscriptions выгружает подписки в формате csv, ndjson или xlsx.
+// Тело ответа закрывает вызывающий.
+func (c *Client) ExportSubscriptions(ctx context.Context, filter ListFilter, format string) (io.ReadCloser, error) {
+	query := listQuery(filter)
+	query.Set("format", format)
+	return c.stream(ctx, request{method: http.MethodGet, path: subscriptionsPath + "/export", query: query})
+}
+
+// CalculateTotalCost возвращает стоимость подписок за период
+func (c *Client) CalculateTotalCost(ctx context.Context, req TotalCostRequest) (TotalCostResponse, error) {
+	var total TotalCostResponse
+	return total, c.do(ctx, request{method: http.MethodGet, path: subscriptionsPath + "/total", query: totalCostQuery(req)}, &total)
+}
+
+// ExportTotalCost выгружает подписки из расчёта стоимости в формате csv, ndjson или xlsx.
+// Тело ответа закрывает вызывающий.
+func (c *Client) ExportTotalCost(ctx context.Context, req TotalCostRequest, format string) (io.ReadCloser, error) {
+	query := totalCostQuery(req)
+	query.Set("format", format)
+	return c.stream(ctx, request{method: http.MethodGet, path: subscriptionsPath + "/total/export", query: query})
+}
+
+// GetSubscriptionHistory возвращает страницу журнала изменений подписки
+func (c *Client) GetSubscriptionHistory(ctx context.Context, id uuid.UUID, page, pageSize int) (AuditList, error) {
+	var list AuditList
+	query := url.Values{}
+	setPage(query, page, pageSize)
+	return list, c.do(ctx, request{method: http.MethodGet, path: subscriptionPath(id) + "/history", query: query}, &list)
+}
+
+// GetAuditLog возвращает страницу журнала изменений всех подписок. Только для администраторов
+func (c *Client) GetAuditLog(ctx context.Context, req AuditLogRequest, page, pageSize int) (AuditList, error) {
+	var list AuditList
+	query := url.Values{}
+	setIf(query, "actor", req.Actor)
+	setIf(query, "from", req.From)
+	setIf(query, "to", req.To)
+	setPage(query, page, pageSize)
+	return list, c.do(ctx, request{method: http.MethodGet, path: "/api/audit", query: query}, &list)
+}
+
+// GetRenewalsCalendarLink возвращает ссылку на календарь продлений. Только для администраторов
+func (c *Client) GetRenewalsCalendarLink(ctx context.Context, userID uuid.UUID) (CalendarLink, error) {
+	var link CalendarLink
+	return link, c.do(ctx, request{method: http.MethodGet, path: "/api/users/" + userID.String() + "/renewals/link"}, &link)
+}
+
+// GetRenewalsCalendar возвращает календарь продлений в формате iCalendar
+func (c *Client) GetRenewalsCalendar(ctx context.Context, userID uuid.UUID, token string) ([]byte, error) {
+	body, err := c.stream(ctx, request{
+		method: http.MethodGet,
+		path:   "/api/users/" + userID.String() + "/renewals.ics",
+		query:  url.Values{"token": {token}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func (c *Client) stream(ctx context.Context, req request) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func subscriptionPath(id uuid.UUID) string {
+	return subscriptionsPath + "/" + id.String()
+}
+
+func ifMatch(version int64) http.Header {
+	if version == 0 {
+		return nil
+	}
+	return http.Header{"If-Match": {`"` + strconv.FormatInt(version, 10) + `"`}}
+}
+
+func listQuery(filter ListFilter) url.Values {
+	query := url.Values{}
+	setIf(query, "user_id", filter.UserID)
+	setIf(query, "service_name", filter.ServiceName)
+	if filter.IncludeDeleted {
+		query.Set("include_deleted", "true")
+	}
+	return query
+}
+
+func totalCostQuery(req TotalCostRequest) url.Values {
+	query := url.Values{}
+	setIf(query, "user_id", req.UserID)
+	setIf(query, "service_name", req.ServiceName)
+	setIf(query, "start_date", req.StartDate)
+	setIf(query, "end_date", req.EndDate)
+	return query
+}
+
+func setPage(query url.Values, page, pageSize int) {
+	if page > 0 {
+		query.Set("page", strconv.Itoa(page))
+	}
+	if pageSize > 0 {
+		query.Set("pageSize", strconv.Itoa(pageSize))
+	}
+}
+
+func setIf(query url.Values, name, value string) {
+	if value != "" {
+		query.Set(name, value)
+	}
+}
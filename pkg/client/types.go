@@ -0,0 +1,51 @@
+package client
+
+import "github.com/BabichevDima/subManager/internal/dto"
+
+// Типы запросов и ответов API. Это псевдонимы DTO сервиса, поэтому клиент
+// и сервер не расходятся в формате.
+type (
+	Subscription        = dto.ResponseSubscription
+	SubscriptionRequest = dto.RequestSubscription
+	// SubscriptionPatch частичное обновление: заполняйте поля через String, Int и Null
+	SubscriptionPatch = dto.UpdateSubscriptionRequest
+	OptionalString    = dto.OptionalString
+	OptionalInt       = dto.OptionalInt
+	SubscriptionList  = dto.SubscriptionListResponse
+	ListFilter        = dto.SubscriptionListRequest
+	Pagination        = dto.PaginationResponse
+	TotalCostRequest  = dto.TotalCostRequest
+	TotalCostResponse = dto.TotalCostResponse
+	BatchOperation    = dto.BatchOperation
+	BatchResponse     = dto.BatchResponse
+	BatchItemResult   = dto.BatchItemResult
+	ImportReport      = dto.ImportReport
+	ImportRowResult   = dto.ImportRowResult
+	AuditEntry        = dto.AuditEntryResponse
+	AuditList         = dto.AuditListResponse
+	AuditLogRequest   = dto.AuditLogRequest
+	CalendarLink      = dto.CalendarLinkResponse
+	Problem           = dto.Problem
+	FieldError        = dto.FieldError
+)
+
+const (
+	BatchOpCreate = dto.BatchOpCreate
+	BatchOpUpdate = dto.BatchOpUpdate
+	BatchOpDelete = dto.BatchOpDelete
+)
+
+// String значение строкового поля патча
+func String(value string) OptionalString {
+	return OptionalString{Set: true, Value: value}
+}
+
+// Int значение числового поля патча
+func Int(value int) OptionalInt {
+	return OptionalInt{Set: true, Value: value}
+}
+
+// Null сбрасывает поле патча; сервер разрешает это только для end_date
+func Null() OptionalString {
+	return OptionalString{Set: true, Null: true}
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -14,19 +15,36 @@ import (
 
 type ShutdownFunc func(ctx context.Context) error
 
+var shuttingDown atomic.Bool
+
+// ShuttingDown сообщает, что получен сигнал остановки
+func ShuttingDown() bool {
+	return shuttingDown.Load()
+}
+
+// GracefulShutdown ждёт SIGINT или SIGTERM и останавливает приложение. Первые drainDelay
+// сервер продолжает отвечать, но ShuttingDown уже возвращает true, чтобы проверка
+// готовности успела вывести экземпляр из балансировки.
 func GracefulShutdown(
 	cancel context.CancelFunc,
 	server *http.Server,
 	log *zap.Logger,
 	timeout time.Duration,
+	drainDelay time.Duration,
 	shutdownFuncs ...ShutdownFunc,
 ) {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	shuttingDown.Store(true)
 	logger.Info("Shutting down server...")
 
+	if drainDelay > 0 {
+		logger.Info("Draining traffic", zap.Duration("delay", drainDelay))
+		time.Sleep(drainDelay)
+	}
+
 	ctx, cancelTimeout := context.WithTimeout(context.Background(), timeout)
 	defer cancelTimeout()
 
@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"context"
+	"fmt"
 	"log"
 
 	"go.uber.org/zap"
@@ -8,6 +10,8 @@ import (
 
 var L *zap.Logger
 
+type ctxKey struct{}
+
 func Init() {
 	var err error
 	L, err = zap.NewProduction()
@@ -16,6 +20,34 @@ func Init() {
 	}
 }
 
+// Configure пересоздаёт L с уровнем и форматом из конфигурации: json для
+// сборщиков логов, console для чтения глазами при разработке
+func Configure(level, format string) error {
+	lvl, err := zap.ParseAtomicLevel(level)
+	if err != nil {
+		return err
+	}
+
+	var cfg zap.Config
+	switch format {
+	case "json":
+		cfg = zap.NewProductionConfig()
+	case "console":
+		cfg = zap.NewDevelopmentConfig()
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	cfg.Level = lvl
+
+	l, err := cfg.Build()
+	if err != nil {
+		return err
+	}
+	_ = L.Sync()
+	L = l
+	return nil
+}
+
 func Info(msg string, fields ...zap.Field) {
 	L.Info(msg, fields...)
 }
@@ -35,3 +67,16 @@ func Debug(msg string, fields ...zap.Field) {
 func Fatal(msg string, fields ...zap.Field) {
 	L.Fatal(msg, fields...)
 }
+
+// WithContext сохраняет в контексте логгер запроса с его идентификаторами
+func WithContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, l)
+}
+
+// FromContext возвращает логгер запроса или общий логгер L, если запроса нет
+func FromContext(ctx context.Context) *zap.Logger {
+	if l, ok := ctx.Value(ctxKey{}).(*zap.Logger); ok {
+		return l
+	}
+	return L
+}